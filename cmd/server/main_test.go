@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"xm-company-service/internal/config"
+	"xm-company-service/internal/core"
+	"xm-company-service/internal/handler"
+	"xm-company-service/internal/middleware"
+	"xm-company-service/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder tracks the order in which shutdown phases run
+type recorder struct {
+	calls []string
+}
+
+type fakeServer struct {
+	rec *recorder
+	err error
+}
+
+func (s *fakeServer) Shutdown(ctx context.Context) error {
+	s.rec.calls = append(s.rec.calls, "http")
+	return s.err
+}
+
+type fakeCloser struct {
+	rec  *recorder
+	name string
+	err  error
+}
+
+func (c *fakeCloser) Close() error {
+	c.rec.calls = append(c.rec.calls, c.name)
+	return c.err
+}
+
+// fakeWaiter records when event subscribers are waited for, blocking
+// until release is closed when it is set
+type fakeWaiter struct {
+	rec     *recorder
+	release chan struct{}
+}
+
+func (w *fakeWaiter) Wait() {
+	if w.release != nil {
+		<-w.release
+	}
+	w.rec.calls = append(w.rec.calls, "events")
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("phases run in order", func(t *testing.T) {
+		rec := &recorder{}
+
+		err := shutdown(
+			&fakeServer{rec: rec},
+			&fakeWaiter{rec: rec},
+			&fakeCloser{rec: rec, name: "producer"},
+			&fakeCloser{rec: rec, name: "db"},
+			time.Second,
+		)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"http", "events", "producer", "db"}, rec.calls)
+	})
+
+	t.Run("failed drain still releases resources", func(t *testing.T) {
+		rec := &recorder{}
+
+		err := shutdown(
+			&fakeServer{rec: rec, err: context.DeadlineExceeded},
+			&fakeWaiter{rec: rec},
+			&fakeCloser{rec: rec, name: "producer", err: errors.New("flush failed")},
+			&fakeCloser{rec: rec, name: "db"},
+			time.Second,
+		)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "flush failed")
+		assert.Equal(t, []string{"http", "events", "producer", "db"}, rec.calls)
+	})
+
+	t.Run("stuck subscriber does not block shutdown", func(t *testing.T) {
+		rec := &recorder{}
+		release := make(chan struct{})
+		defer close(release)
+
+		err := shutdown(
+			&fakeServer{rec: rec},
+			&fakeWaiter{rec: &recorder{}, release: release},
+			&fakeCloser{rec: rec, name: "producer"},
+			&fakeCloser{rec: rec, name: "db"},
+			10*time.Millisecond,
+		)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, []string{"http", "producer", "db"}, rec.calls)
+	})
+}
+
+func TestNewServer_HTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    int
+	}{
+		{"h2c enabled", true, 2},
+		{"h2c disabled", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(config.ServerConfig{
+				IdleTimeout:    time.Minute,
+				MaxHeaderBytes: 1 << 20,
+				HTTP2Enabled:   tt.enabled,
+			}, handler)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go srv.Serve(ln)
+
+			// The client speaks h2c with prior knowledge, falling back to HTTP/1.1
+			transport := &http.Transport{Protocols: new(http.Protocols)}
+			transport.Protocols.SetHTTP1(!tt.enabled)
+			transport.Protocols.SetUnencryptedHTTP2(tt.enabled)
+			client := &http.Client{Transport: transport}
+
+			resp, err := client.Get("http://" + ln.Addr().String())
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.want, resp.ProtoMajor)
+
+			// Graceful shutdown still completes with idle h2c connections
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NoError(t, srv.Shutdown(ctx))
+		})
+	}
+}
+
+// fakeProducer records the event types it is asked to publish
+type fakeProducer struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (p *fakeProducer) Publish(ctx context.Context, eventType string, payload interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, eventType)
+	return nil
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+func (p *fakeProducer) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.events...)
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	t.Run("publishes until stopped", func(t *testing.T) {
+		producer := &fakeProducer{}
+
+		stop := startHeartbeat(producer, 5*time.Millisecond)
+		require.Eventually(t, func() bool {
+			return len(producer.published()) >= 2
+		}, time.Second, time.Millisecond)
+		stop()
+
+		events := producer.published()
+		for _, event := range events {
+			assert.Equal(t, "ServiceHeartbeat", event)
+		}
+		time.Sleep(20 * time.Millisecond)
+		assert.Len(t, producer.published(), len(events), "no heartbeats after stop")
+	})
+
+	t.Run("zero interval disables", func(t *testing.T) {
+		producer := &fakeProducer{}
+
+		stop := startHeartbeat(producer, 0)
+		time.Sleep(20 * time.Millisecond)
+		stop()
+
+		assert.Empty(t, producer.published())
+	})
+}
+
+// fakeSelfTester is a database whose self-test returns err
+type fakeSelfTester struct {
+	err error
+}
+
+func (f fakeSelfTester) SelfTest(ctx context.Context) error { return f.err }
+
+func TestRunSelfTest(t *testing.T) {
+	t.Run("publishes the probe to the self-test topic", func(t *testing.T) {
+		probe := &fakeProducer{}
+		var topics []string
+		newProbe := func(topic string) core.EventProducer {
+			topics = append(topics, topic)
+			return probe
+		}
+
+		require.NoError(t, runSelfTest(fakeSelfTester{}, "company-events.deadletter", newProbe))
+
+		assert.Equal(t, []string{"company-events.deadletter"}, topics)
+		assert.Equal(t, []string{"ServiceSelfTest"}, probe.published())
+	})
+
+	t.Run("no topic skips the publish check", func(t *testing.T) {
+		newProbe := func(topic string) core.EventProducer {
+			t.Fatalf("probe producer created for %q", topic)
+			return nil
+		}
+
+		assert.NoError(t, runSelfTest(fakeSelfTester{}, "", newProbe))
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		err := runSelfTest(fakeSelfTester{err: errors.New("permission denied")}, "", nil)
+		assert.EqualError(t, err, "database: permission denied")
+	})
+}
+
+// allEndpoints enables every mutating endpoint
+var allEndpoints = config.EndpointsConfig{Create: true, Patch: true, Delete: true}
+
+// fakeRepository serves a single company; other methods are unused
+type fakeRepository struct {
+	core.Repository
+	company *core.Company
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, id uuid.UUID) (*core.Company, error) {
+	if id != r.company.ID {
+		return nil, core.ErrNotFound
+	}
+	return r.company, nil
+}
+
+func TestSetupRouter_TrailingSlash(t *testing.T) {
+	company := &core.Company{ID: uuid.New(), Name: "Acme", Employees: 10, Registered: true, Type: core.TypeCorporations}
+	path := "/companies/" + company.ID.String()
+
+	tests := []struct {
+		mode         string
+		wantSlashed  int
+		wantLocation string
+	}{
+		{"strip", http.StatusOK, ""},
+		{"redirect", http.StatusMovedPermanently, path},
+		{"strict", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			slashes, err := trailingSlashes(tt.mode)
+			require.NoError(t, err)
+
+			svc := service.NewCompanyService(&fakeRepository{company: company}, &fakeProducer{})
+			r := setupRouter(handler.NewHandler(svc), handler.NewHealthHandler(nil, nil), handler.NewAdminHandler(nil, nil, nil), routerOptions{
+				requestTimeout:  time.Second,
+				slashes:         slashes,
+				endpoints:       allEndpoints,
+				accessLogSample: 1,
+				requestIDHeader: middleware.DefaultRequestIDHeader,
+			})
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			rec = httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"/", nil))
+			assert.Equal(t, tt.wantSlashed, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+
+	t.Run("unknown mode", func(t *testing.T) {
+		_, err := trailingSlashes("lenient")
+		assert.Error(t, err)
+	})
+}
+
+func TestSetupRouter_MethodNotAllowed(t *testing.T) {
+	company := &core.Company{ID: uuid.New(), Name: "Acme", Employees: 10, Registered: true, Type: core.TypeCorporations}
+	path := "/companies/" + company.ID.String()
+
+	newRouter := func(t *testing.T, endpoints config.EndpointsConfig, slashMode string) http.Handler {
+		slashes, err := trailingSlashes(slashMode)
+		require.NoError(t, err)
+		svc := service.NewCompanyService(&fakeRepository{company: company}, &fakeProducer{})
+		return setupRouter(handler.NewHandler(svc), handler.NewHealthHandler(nil, nil), handler.NewAdminHandler(nil, nil, nil), routerOptions{
+			adminKey:        "secret",
+			requestTimeout:  time.Second,
+			slashes:         slashes,
+			endpoints:       endpoints,
+			accessLogSample: 1,
+			requestIDHeader: middleware.DefaultRequestIDHeader,
+		})
+	}
+
+	tests := []struct {
+		name      string
+		endpoints config.EndpointsConfig
+		slashMode string
+		method    string
+		path      string
+		header    http.Header
+		wantAllow string
+	}{
+		{"company", allEndpoints, "strict", http.MethodPut, path, nil, "GET, PATCH, DELETE"},
+		{"collection", allEndpoints, "strict", http.MethodPut, "/companies", nil, "HEAD, POST, PATCH"},
+		{"disabled endpoint", config.EndpointsConfig{Patch: true}, "strict", http.MethodDelete, path, nil, "GET, PATCH"},
+		{"stripped slash", allEndpoints, "strip", http.MethodPut, path + "/", nil, "GET, PATCH, DELETE"},
+		{"subrouter", allEndpoints, "strict", http.MethodPatch, "/admin/events", http.Header{"X-Admin-Key": {"secret"}}, "GET, PUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			rec := httptest.NewRecorder()
+
+			newRouter(t, tt.endpoints, tt.slashMode).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+			assert.Equal(t, tt.wantAllow, rec.Header().Get("Allow"))
+			assert.JSONEq(t, `{"error":"method not allowed","code":"METHOD_NOT_ALLOWED"}`, rec.Body.String())
+		})
+	}
+}
+
+func TestSetupRouter_Endpoints(t *testing.T) {
+	company := &core.Company{ID: uuid.New(), Name: "Acme", Employees: 10, Registered: true, Type: core.TypeCorporations}
+	path := "/companies/" + company.ID.String()
+
+	newRouter := func(endpoints config.EndpointsConfig) http.Handler {
+		svc := service.NewCompanyService(&fakeRepository{company: company}, &fakeProducer{})
+		return setupRouter(handler.NewHandler(svc), handler.NewHealthHandler(nil, nil), handler.NewAdminHandler(nil, nil, nil), routerOptions{
+			requestTimeout:  time.Second,
+			endpoints:       endpoints,
+			accessLogSample: 1,
+			requestIDHeader: middleware.DefaultRequestIDHeader,
+		})
+	}
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/companies"},
+		{http.MethodPatch, path},
+		{http.MethodDelete, path},
+	}
+
+	t.Run("enabled endpoints require authentication", func(t *testing.T) {
+		r := newRouter(allEndpoints)
+		for _, req := range requests {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, nil))
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, "%s %s", req.method, req.path)
+		}
+	})
+
+	t.Run("disabled endpoints return 405", func(t *testing.T) {
+		r := newRouter(config.EndpointsConfig{})
+		for _, req := range requests {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, nil))
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code, "%s %s", req.method, req.path)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("endpoints toggle independently", func(t *testing.T) {
+		r := newRouter(config.EndpointsConfig{Patch: true})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestMaskDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{"url", "postgres://xm:s3cret@db:5432/xm?sslmode=disable", "postgres://xm:****@db:5432/xm?sslmode=disable"},
+		{"url with escaped password", "postgres://xm:p%40ss%2Fw@db/xm", "postgres://xm:****@db/xm"},
+		{"url with escaped user", "postgresql://x%40m:pw@db/xm", "postgresql://x%40m:****@db/xm"},
+		{"url without password", "postgres://xm@db:5432/xm", "postgres://xm@db:5432/xm"},
+		{"url without user", "postgres://db:5432/xm?sslmode=disable", "postgres://db:5432/xm?sslmode=disable"},
+		{"password query parameter", "postgres://db/xm?user=xm&password=s3cret&sslmode=disable", "postgres://db/xm?user=xm&password=****&sslmode=disable"},
+		{"key=value", "host=db port=5432 user=xm password=s3cret dbname=xm", "host=db port=5432 user=xm password=**** dbname=xm"},
+		{"key=value quoted password", "host=db password='s3 cr\\'et' dbname=xm", "host=db password=**** dbname=xm"},
+		{"key=value without password", "host=db user=xm dbname=xm", "host=db user=xm dbname=xm"},
+		{"malformed url", "postgres://xm:bad%zz@db/xm", "****"},
+		{"unrecognized", "garbage", "****"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, maskDSN(tt.dsn))
+		})
+	}
+}
@@ -1,6 +1,6 @@
 module xm-company-service
 
-go 1.23
+go 1.24
 
 require (
 	github.com/go-chi/chi/v5 v5.2.3
@@ -16,5 +16,6 @@ require (
 	github.com/klauspost/compress v1.15.9 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.5.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SecretProvider resolves sensitive configuration values by name
+type SecretProvider interface {
+	// Lookup returns the secret for key and whether it was set
+	Lookup(key string) (string, bool, error)
+}
+
+// EnvSecrets reads secrets from environment variables
+type EnvSecrets struct{}
+
+// Lookup returns the environment variable key
+func (EnvSecrets) Lookup(key string) (string, bool, error) {
+	value := os.Getenv(key)
+	return value, value != "", nil
+}
+
+// FileSecrets reads a secret from the file named by <key>_FILE, as mounted
+// by Docker and Kubernetes secrets, and falls back to Next when that
+// variable is unset
+type FileSecrets struct {
+	Next SecretProvider
+}
+
+// Lookup returns the trimmed contents of the <key>_FILE file
+func (p FileSecrets) Lookup(key string) (string, bool, error) {
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("read %s_FILE: %w", key, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	if p.Next == nil {
+		return "", false, nil
+	}
+	return p.Next.Lookup(key)
+}
+
+// DefaultSecrets prefers <key>_FILE secrets over plain environment variables
+func DefaultSecrets() SecretProvider {
+	return FileSecrets{Next: EnvSecrets{}}
+}
+
+func getSecret(secrets SecretProvider, key, defaultValue string) (string, error) {
+	value, ok, err := secrets.Lookup(key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return defaultValue, nil
+	}
+	return value, nil
+}
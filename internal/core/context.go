@@ -0,0 +1,20 @@
+package core
+
+import "context"
+
+type contextKey string
+
+// correlationIDKey is the context key for the request's correlation ID
+const correlationIDKey contextKey = "correlationID"
+
+// WithCorrelationID returns a context carrying id, which ties events and
+// logs back to the request that caused them
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or ""
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
@@ -1,8 +1,11 @@
 package core
 
 import (
-	"errors"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -25,35 +28,136 @@ var ValidCompanyTypes = []CompanyType{
 	TypeSoleProprietorship,
 }
 
+const (
+	// DefaultMaxNameLength is the name limit used unless MAX_NAME_LENGTH overrides it
+	DefaultMaxNameLength = 15
+
+	// NameColumnLength is the width of the name column; MaxNameLength must not exceed it
+	NameColumnLength = 255
+
+	// DefaultMaxDescriptionLength is the description limit used unless
+	// MAX_DESCRIPTION_LENGTH overrides it. The column is TEXT, so the limit
+	// is enforced by the application only.
+	DefaultMaxDescriptionLength = 3000
+)
+
+// MaxNameLength is the maximum company name length enforced by Validate.
+// It is set once at startup from configuration.
+var MaxNameLength = DefaultMaxNameLength
+
+// MaxDescriptionLength is the maximum description length enforced by
+// Validate. It is set once at startup from configuration.
+var MaxDescriptionLength = DefaultMaxDescriptionLength
+
+// ValidationError reports input that breaks a business rule
+type ValidationError struct {
+	Field   string
+	Message string
+	// Err, when set, is a sentinel identifying the rule that failed
+	Err error
+}
+
+// NewValidationError creates a ValidationError for field
+func NewValidationError(field, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
 // Company represents the company entity
 type Company struct {
-	ID          uuid.UUID   `json:"id"`
-	Name        string      `json:"name"`                  // Required, max 15 chars, unique
-	Description *string     `json:"description,omitempty"` // Optional, max 3000 chars
-	Employees   int         `json:"employees"`             // Required
-	Registered  bool        `json:"registered"`            // Required
-	Type        CompanyType `json:"type"`                  // Required
+	ID          uuid.UUID   `json:"id" xml:"id"`
+	Name        string      `json:"name" xml:"name"`                                   // Required, max MaxNameLength chars, unique
+	Description *string     `json:"description,omitempty" xml:"description,omitempty"` // Optional, max MaxDescriptionLength chars
+	Employees   int         `json:"employees" xml:"employees"`                         // Required
+	Registered  bool        `json:"registered" xml:"registered"`                       // Required
+	Type        CompanyType `json:"type" xml:"type"`                                   // Required
+	CreatedAt   *time.Time  `json:"createdAt,omitempty" xml:"createdAt,omitempty"`     // Set by the repository on create
+}
+
+// CompanyName is the minimal view of a company used for autocomplete
+type CompanyName struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// MaxSimilarNameDistance is the largest case-insensitive edit distance at
+// which two company names count as near-duplicates
+const MaxSimilarNameDistance = 1
+
+// NamesSimilar reports whether a and b are near-duplicates: within
+// MaxSimilarNameDistance single-character edits of each other, ignoring case
+func NamesSimilar(a, b string) bool {
+	return editDistance(strings.ToLower(a), strings.ToLower(b)) <= MaxSimilarNameDistance
+}
+
+// editDistance is the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// EmployeeAggregates summarizes employee counts across companies.
+// All values are zero when no company matches.
+type EmployeeAggregates struct {
+	Sum int64   `json:"sum"`
+	Avg float64 `json:"avg"`
+	Max int     `json:"max"`
+	Min int     `json:"min"`
+}
+
+// EmployeeBucket counts the companies whose employee count lies in
+// [Min, Max); Max is nil for the open-ended last bucket
+type EmployeeBucket struct {
+	Min   int   `json:"min"`
+	Max   *int  `json:"max,omitempty"`
+	Count int64 `json:"count"`
+}
+
+// Normalize canonicalizes user input before validation
+func (c *Company) Normalize() {
+	c.Name = strings.TrimSpace(c.Name)
 }
 
 // Validate enforces business rules
 func (c *Company) Validate() error {
 	if c.Name == "" {
-		return errors.New("name is required")
+		return NewValidationError("name", "name is required")
 	}
-	if len(c.Name) > 15 {
-		return errors.New("name must be 15 characters or fewer")
+	if len(c.Name) > MaxNameLength {
+		return NewValidationError("name", "name must be %d characters or fewer", MaxNameLength)
 	}
 
-	if c.Description != nil && len(*c.Description) > 3000 {
-		return errors.New("description must be 3000 characters or fewer")
+	if c.Description != nil && len(*c.Description) > MaxDescriptionLength {
+		err := NewValidationError("description", "description must be %d characters or fewer", MaxDescriptionLength)
+		err.Err = ErrDescriptionTooLong
+		return err
 	}
 
 	if c.Employees < 0 {
-		return errors.New("employees cannot be negative")
+		return NewValidationError("employees", "employees cannot be negative")
 	}
 
 	if !c.Type.IsValid() {
-		return fmt.Errorf("invalid company type: %s", c.Type)
+		return NewValidationError("type", "invalid company type: %s", c.Type)
 	}
 
 	return nil
@@ -69,6 +173,116 @@ func (ct CompanyType) IsValid() bool {
 	}
 }
 
+// ParseCompanyType returns the company type matching s, ignoring case and
+// surrounding whitespace, so "sole proprietorship" yields
+// TypeSoleProprietorship
+func ParseCompanyType(s string) (CompanyType, error) {
+	s = strings.TrimSpace(s)
+	for _, ct := range ValidCompanyTypes {
+		if strings.EqualFold(s, string(ct)) {
+			return ct, nil
+		}
+	}
+	return "", NewValidationError("type", "invalid company type: %s", s)
+}
+
+// UnmarshalJSON parses a type string with ParseCompanyType, so request
+// bodies with an unknown type fail at decode time. null leaves ct unchanged
+// and "" decodes to the zero value, which Validate rejects.
+func (ct *CompanyType) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return NewValidationError("type", "type must be a string")
+	}
+	if s == "" {
+		*ct = ""
+		return nil
+	}
+	parsed, err := ParseCompanyType(s)
+	if err != nil {
+		return err
+	}
+	*ct = parsed
+	return nil
+}
+
+// UnmarshalXML parses a type element with ParseCompanyType
+func (ct *CompanyType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	parsed, err := ParseCompanyType(s)
+	if err != nil {
+		return err
+	}
+	*ct = parsed
+	return nil
+}
+
+// UnmarshalXMLAttr parses a type attribute with ParseCompanyType
+func (ct *CompanyType) UnmarshalXMLAttr(attr xml.Attr) error {
+	parsed, err := ParseCompanyType(attr.Value)
+	if err != nil {
+		return err
+	}
+	*ct = parsed
+	return nil
+}
+
+// FieldChange records a field's value before and after an update
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// CompanyUpdatedEvent is the payload of CompanyUpdated events: the full
+// updated company plus the fields that changed, keyed by JSON field name
+type CompanyUpdatedEvent struct {
+	*Company
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+// CompanyDeletedEvent is the payload of CompanyDeleted events
+type CompanyDeletedEvent struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// Diff returns the mutable fields that differ between before and after,
+// keyed by JSON field name. Unchanged fields are omitted.
+func Diff(before, after *Company) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+
+	if before.Name != after.Name {
+		changes["name"] = FieldChange{Old: before.Name, New: after.Name}
+	}
+	if !equalStringPtr(before.Description, after.Description) {
+		changes["description"] = FieldChange{Old: before.Description, New: after.Description}
+	}
+	if before.Employees != after.Employees {
+		changes["employees"] = FieldChange{Old: before.Employees, New: after.Employees}
+	}
+	if before.Registered != after.Registered {
+		changes["registered"] = FieldChange{Old: before.Registered, New: after.Registered}
+	}
+	if before.Type != after.Type {
+		changes["type"] = FieldChange{Old: before.Type, New: after.Type}
+	}
+
+	return changes
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // CompanyEvent represents an event emitted on mutations
 type CompanyEvent struct {
 	Type    string      `json:"type"`
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"strings"
 	"testing"
 
@@ -129,11 +131,159 @@ func TestCompany_Validate(t *testing.T) {
 			} else {
 				require.Error(t, err)
 				assert.Contains(t, err.Error(), tt.wantErr)
+				var validationErr *ValidationError
+				assert.ErrorAs(t, err, &validationErr)
 			}
 		})
 	}
 }
 
+func TestCompany_Validate_ConfiguredNameLength(t *testing.T) {
+	defer func(prev int) { MaxNameLength = prev }(MaxNameLength)
+	MaxNameLength = 20
+
+	tests := []struct {
+		name    string
+		length  int
+		wantErr string
+	}{
+		{"one below limit", 19, ""},
+		{"at limit", 20, ""},
+		{"one above limit", 21, "name must be 20 characters or fewer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Company{
+				ID:         uuid.New(),
+				Name:       strings.Repeat("a", tt.length),
+				Employees:  1,
+				Registered: true,
+				Type:       TypeCorporations,
+			}
+
+			err := c.Validate()
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestCompany_Validate_ConfiguredDescriptionLength(t *testing.T) {
+	defer func(prev int) { MaxDescriptionLength = prev }(MaxDescriptionLength)
+	MaxDescriptionLength = 5000
+
+	tests := []struct {
+		name    string
+		length  int
+		wantErr string
+	}{
+		{"one below limit", 4999, ""},
+		{"at limit", 5000, ""},
+		{"one above limit", 5001, "description must be 5000 characters or fewer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description := strings.Repeat("a", tt.length)
+			c := Company{
+				ID:          uuid.New(),
+				Name:        "DescCo",
+				Description: &description,
+				Employees:   1,
+				Registered:  true,
+				Type:        TypeCorporations,
+			}
+
+			err := c.Validate()
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				assert.ErrorIs(t, err, ErrDescriptionTooLong)
+			}
+		})
+	}
+}
+
+func TestCompany_Normalize(t *testing.T) {
+	c := Company{Name: "  Acme\t"}
+	c.Normalize()
+	assert.Equal(t, "Acme", c.Name)
+
+	blank := Company{Name: "   ", Employees: 1, Type: TypeCorporations}
+	blank.Normalize()
+	assert.EqualError(t, blank.Validate(), "name is required")
+}
+
+func TestNamesSimilar(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Acme", "Acme", true},
+		{"Acme", "ACME", true},
+		{"Acme", "Acme2", true},
+		{"Acme", "Acm", true},
+		{"Acme", "Acne", true},
+		{"Zürich AG", "zurich AG", true},
+		{"Acme", "Acme Inc", false},
+		{"Acme", "Amce", false},
+		{"Acme", "Globex", false},
+		{"", "A", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NamesSimilar(tt.a, tt.b), "%q vs %q", tt.a, tt.b)
+		assert.Equal(t, tt.want, NamesSimilar(tt.b, tt.a), "%q vs %q", tt.b, tt.a)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	desc := "A description"
+	otherDesc := "A description"
+	before := Company{
+		ID:          uuid.New(),
+		Name:        "Before",
+		Description: &desc,
+		Employees:   10,
+		Registered:  false,
+		Type:        TypeCorporations,
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		after := before
+		after.Description = &otherDesc // same value, different pointer
+
+		assert.Empty(t, Diff(&before, &after))
+	})
+
+	t.Run("all fields changed", func(t *testing.T) {
+		after := Company{
+			ID:         before.ID,
+			Name:       "After",
+			Employees:  20,
+			Registered: true,
+			Type:       TypeNonProfit,
+		}
+
+		assert.Equal(t, map[string]FieldChange{
+			"name":        {Old: "Before", New: "After"},
+			"description": {Old: &desc, New: (*string)(nil)},
+			"employees":   {Old: 10, New: 20},
+			"registered":  {Old: false, New: true},
+			"type":        {Old: TypeCorporations, New: TypeNonProfit},
+		}, Diff(&before, &after))
+	})
+}
+
 func TestCompanyType_IsValid(t *testing.T) {
 	tests := []struct {
 		companyType CompanyType
@@ -156,6 +306,116 @@ func TestCompanyType_IsValid(t *testing.T) {
 	}
 }
 
+func TestParseCompanyType(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    CompanyType
+		wantErr bool
+	}{
+		{"Corporations", TypeCorporations, false},
+		{"nonprofit", TypeNonProfit, false},
+		{" COOPERATIVE ", TypeCooperative, false},
+		{"sole proprietorship", TypeSoleProprietorship, false},
+		{"SoleProprietorship", "", true},
+		{"Bogus", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseCompanyType(tt.in)
+			if tt.wantErr {
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, "type", validationErr.Field)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompanyType_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    CompanyType
+		wantErr string
+	}{
+		{`"NonProfit"`, TypeNonProfit, ""},
+		{`"nonPROFIT"`, TypeNonProfit, ""},
+		{`"Sole Proprietorship"`, TypeSoleProprietorship, ""},
+		{`""`, "", ""},
+		{`"Bogus"`, "", "invalid company type: Bogus"},
+		{`42`, "", "type must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var company Company
+			err := json.Unmarshal([]byte(`{"type":`+tt.in+`}`), &company)
+			if tt.wantErr != "" {
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, tt.wantErr, validationErr.Message)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, company.Type)
+		})
+	}
+
+	t.Run("null keeps the current value", func(t *testing.T) {
+		company := Company{Type: TypeCooperative}
+		require.NoError(t, json.Unmarshal([]byte(`{"type":null}`), &company))
+		assert.Equal(t, TypeCooperative, company.Type)
+	})
+}
+
+func TestCompanyType_XML(t *testing.T) {
+	for _, ct := range ValidCompanyTypes {
+		t.Run(string(ct), func(t *testing.T) {
+			// Element round trip, as in the company XML representation
+			data, err := xml.Marshal(Company{Name: "Acme", Type: ct})
+			require.NoError(t, err)
+			var company Company
+			require.NoError(t, xml.Unmarshal(data, &company))
+			assert.Equal(t, ct, company.Type)
+
+			// Attribute round trip
+			var attr struct {
+				XMLName xml.Name    `xml:"company"`
+				Type    CompanyType `xml:"type,attr"`
+			}
+			attr.Type = ct
+			data, err = xml.Marshal(attr)
+			require.NoError(t, err)
+			attr.Type = ""
+			require.NoError(t, xml.Unmarshal(data, &attr))
+			assert.Equal(t, ct, attr.Type)
+		})
+	}
+
+	t.Run("case insensitive", func(t *testing.T) {
+		var company Company
+		require.NoError(t, xml.Unmarshal([]byte(`<Company><type>sole PROPRIETORSHIP</type></Company>`), &company))
+		assert.Equal(t, TypeSoleProprietorship, company.Type)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		var company Company
+		err := xml.Unmarshal([]byte(`<Company><type>Bogus</type></Company>`), &company)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "invalid company type: Bogus", validationErr.Message)
+
+		var attr struct {
+			Type CompanyType `xml:"type,attr"`
+		}
+		assert.Error(t, xml.Unmarshal([]byte(`<company type="Bogus"/>`), &attr))
+	})
+}
+
 func strPtr(s string) *string {
 	return &s
 }
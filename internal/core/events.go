@@ -0,0 +1,58 @@
+package core
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// EventBus delivers events to in-process subscribers. Each handler runs in
+// its own goroutine so a slow or panicking subscriber cannot block or crash
+// the request path.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(payload interface{})
+	wg       sync.WaitGroup
+}
+
+// NewEventBus creates an event bus with no subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: make(map[string][]func(payload interface{})),
+	}
+}
+
+// Subscribe registers handler for events of eventType
+func (b *EventBus) Subscribe(eventType string, handler func(payload interface{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish dispatches payload to every subscriber of eventType and returns
+// without waiting for them
+func (b *EventBus) Publish(eventType string, payload interface{}) {
+	b.mu.RLock()
+	handlers := b.handlers[eventType]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		b.wg.Add(1)
+		go b.dispatch(eventType, handler, payload)
+	}
+}
+
+// Wait blocks until all dispatched handlers have returned
+func (b *EventBus) Wait() {
+	b.wg.Wait()
+}
+
+func (b *EventBus) dispatch(eventType string, handler func(payload interface{}), payload interface{}) {
+	defer b.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Warning: %s subscriber panicked: %v\n%s", eventType, r, debug.Stack())
+		}
+	}()
+	handler(payload)
+}
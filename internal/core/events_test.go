@@ -0,0 +1,70 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	t.Run("subscribers receive their event type only", func(t *testing.T) {
+		bus := NewEventBus()
+
+		var mu sync.Mutex
+		var got []interface{}
+		bus.Subscribe("CompanyCreated", func(payload interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, payload)
+		})
+
+		bus.Publish("CompanyCreated", "created")
+		bus.Publish("CompanyDeleted", "deleted")
+		bus.Wait()
+
+		assert.Equal(t, []interface{}{"created"}, got)
+	})
+
+	t.Run("panicking subscriber does not affect others", func(t *testing.T) {
+		bus := NewEventBus()
+
+		delivered := make(chan interface{}, 1)
+		bus.Subscribe("CompanyCreated", func(payload interface{}) {
+			panic("boom")
+		})
+		bus.Subscribe("CompanyCreated", func(payload interface{}) {
+			delivered <- payload
+		})
+
+		assert.NotPanics(t, func() {
+			bus.Publish("CompanyCreated", "created")
+			bus.Wait()
+		})
+		assert.Equal(t, "created", <-delivered)
+	})
+
+	t.Run("slow subscriber does not block publish", func(t *testing.T) {
+		bus := NewEventBus()
+
+		release := make(chan struct{})
+		bus.Subscribe("CompanyCreated", func(payload interface{}) {
+			<-release
+		})
+
+		published := make(chan struct{})
+		go func() {
+			bus.Publish("CompanyCreated", "created")
+			close(published)
+		}()
+
+		select {
+		case <-published:
+		case <-time.After(time.Second):
+			t.Fatal("Publish blocked on a slow subscriber")
+		}
+		close(release)
+		bus.Wait()
+	})
+}
@@ -3,6 +3,8 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,7 +15,47 @@ type Repository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*Company, error)
 	GetByName(ctx context.Context, name string) (*Company, error)
 	Update(ctx context.Context, company *Company) error
+	// UpdateMany updates all companies in one transaction. A failure
+	// attributable to one company is returned as an *ItemError.
+	UpdateMany(ctx context.Context, companies []*Company) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// EmployeeAggregates summarizes employee counts, optionally for one
+	// company type; an empty companyType covers all companies
+	EmployeeAggregates(ctx context.Context, companyType CompanyType) (*EmployeeAggregates, error)
+	// EmployeeDistribution counts companies per employee range. bounds are
+	// ascending lower bounds; the last bucket is open-ended and companies
+	// below bounds[0] are not counted.
+	EmployeeDistribution(ctx context.Context, bounds []int) ([]EmployeeBucket, error)
+	// SearchByName returns companies whose name contains q, ignoring case,
+	// ordered by name
+	SearchByName(ctx context.Context, q string, limit, offset int) ([]*Company, error)
+	// NamePrefixSearch returns the companies whose name starts with prefix,
+	// ignoring case, ordered by name
+	NamePrefixSearch(ctx context.Context, prefix string, limit int) ([]*CompanyName, error)
+	// FindSimilarNames returns the companies whose name is similar to name,
+	// see NamesSimilar
+	FindSimilarNames(ctx context.Context, name string) ([]*CompanyName, error)
+	// Count returns the number of companies, optionally of one company
+	// type; an empty companyType counts all companies
+	Count(ctx context.Context, companyType CompanyType) (int, error)
+	// ApproxCount estimates the number of companies from planner
+	// statistics without scanning the table. The estimate is negative when
+	// the table has never been analyzed.
+	ApproxCount(ctx context.Context) (int, error)
+}
+
+// UniquenessChecker enforces unique company names ahead of a write, so the
+// rule does not depend on a storage constraint. Reserve fails with
+// ErrDuplicateName when name is taken or reserved; otherwise the caller
+// must call release once its write has completed or failed.
+type UniquenessChecker interface {
+	Reserve(ctx context.Context, name string) (release func(), err error)
+}
+
+// CacheFlusher is implemented by caching repository decorators. FlushCache
+// drops every cached company and returns how many entries were dropped.
+type CacheFlusher interface {
+	FlushCache(ctx context.Context) (int, error)
 }
 
 // EventProducer defines the contract for publishing events
@@ -27,3 +69,61 @@ var ErrNotFound = errors.New("company not found")
 
 // ErrDuplicateName is returned when a company name already exists
 var ErrDuplicateName = errors.New("company name already exists")
+
+// ErrDuplicateID is returned when a client-supplied company ID already exists
+var ErrDuplicateID = errors.New("company ID already exists")
+
+// ErrUnavailable is returned while an operation is temporarily refused,
+// e.g. during a maintenance window
+var ErrUnavailable = errors.New("service temporarily unavailable")
+
+// ErrQuotaExceeded is returned when creating a company would exceed the
+// configured maximum number of companies
+var ErrQuotaExceeded = errors.New("company quota exceeded")
+
+// ErrReadOnly is returned when a write hits a database that is temporarily
+// read-only, e.g. while a replica is being promoted during failover
+var ErrReadOnly = errors.New("database is read-only, retry shortly")
+
+// ErrBatchAborted is reported for batch items that were valid but not
+// applied because another item in the same all-or-nothing batch failed
+var ErrBatchAborted = errors.New("not applied: another item in the batch failed")
+
+// ErrDescriptionTooLong is wrapped by the ValidationError for a description
+// over MaxDescriptionLength
+var ErrDescriptionTooLong = errors.New("description too long")
+
+// ItemError attributes a batch failure to one company
+type ItemError struct {
+	ID  uuid.UUID
+	Err error
+}
+
+func (e *ItemError) Error() string { return fmt.Sprintf("company %s: %v", e.ID, e.Err) }
+
+func (e *ItemError) Unwrap() error { return e.Err }
+
+// NewDuplicateNameError wraps ErrDuplicateName with the conflicting name.
+// errors.Is(err, ErrDuplicateName) still holds for the returned error.
+func NewDuplicateNameError(name string) error {
+	if name == "" {
+		return ErrDuplicateName
+	}
+	return fmt.Errorf("%w: %q", ErrDuplicateName, name)
+}
+
+// RetryAfterError marks an error as transient: the same request may
+// succeed once the given delay has passed.
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+// WithRetryAfter annotates err with a retry hint for clients
+func WithRetryAfter(err error, delay time.Duration) error {
+	return &RetryAfterError{Err: err, Delay: delay}
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// negotiate picks the offer named by the earliest matching media range in
+// Accept; q-values are not weighed. Wildcard ranges and a missing or
+// unmatched Accept choose offers[0], the default.
+func negotiate(r *http.Request, offers ...string) string {
+	for _, header := range r.Header.Values("Accept") {
+		for _, token := range strings.Split(header, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(token))
+			if err != nil {
+				continue
+			}
+			for _, offer := range offers {
+				if mediaRangeMatches(mediaType, offer) {
+					return offer
+				}
+			}
+		}
+	}
+	return offers[0]
+}
+
+// mediaRangeMatches reports whether an Accept media range such as
+// "text/*" covers mediaType
+func mediaRangeMatches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// acceptsXML reports whether the client asked for XML rather than JSON
+func acceptsXML(r *http.Request) bool {
+	return negotiate(r, "application/json", "application/xml", "text/xml") != "application/json"
+}
+
+// acceptsHTML reports whether the client, typically a browser, prefers an
+// HTML page to JSON
+func acceptsHTML(r *http.Request) bool {
+	return negotiate(r, "application/json", "text/html") == "text/html"
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Migrator applies schema migrations on demand
+type Migrator interface {
+	Migrate(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+// EventSwitch turns event emission on and off at runtime
+type EventSwitch interface {
+	EventsEnabled() bool
+	SetEventsEnabled(enabled bool)
+}
+
+// CacheFlusher empties the company cache on demand
+type CacheFlusher interface {
+	FlushCache(ctx context.Context) (int, error)
+}
+
+// AdminHandler handles operational endpoints
+type AdminHandler struct {
+	migrator Migrator
+	events   EventSwitch
+	cache    CacheFlusher
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(migrator Migrator, events EventSwitch, cache CacheFlusher) *AdminHandler {
+	return &AdminHandler{migrator: migrator, events: events, cache: cache}
+}
+
+// MigrateResponse reports the schema version after migrating
+type MigrateResponse struct {
+	Version int `json:"version"`
+}
+
+// Migrate handles POST /admin/migrate
+func (h *AdminHandler) Migrate(w http.ResponseWriter, r *http.Request) {
+	if err := h.migrator.Migrate(r.Context()); err != nil {
+		log.Printf("Migration failed: %v", err)
+		respondError(w, r, "migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	version, err := h.migrator.SchemaVersion(r.Context())
+	if err != nil {
+		log.Printf("Reading schema version failed: %v", err)
+		respondError(w, r, "migration applied but schema version is unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, MigrateResponse{Version: version}, http.StatusOK)
+}
+
+// EventsRequest is the body of PUT /admin/events
+type EventsRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// EventsResponse reports whether events are emitted
+type EventsResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Events handles GET /admin/events
+func (h *AdminHandler) Events(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, EventsResponse{Enabled: h.events.EventsEnabled()}, http.StatusOK)
+}
+
+// SetEvents handles PUT /admin/events, turning event emission on or off
+// without a redeploy
+func (h *AdminHandler) SetEvents(w http.ResponseWriter, r *http.Request) {
+	var req EventsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		respondError(w, r, `body must be {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+
+	h.events.SetEventsEnabled(*req.Enabled)
+	log.Printf("Event emission enabled=%t via admin endpoint", *req.Enabled)
+
+	respondJSON(w, EventsResponse{Enabled: *req.Enabled}, http.StatusOK)
+}
+
+// FlushCacheResponse reports how many cache entries were dropped
+type FlushCacheResponse struct {
+	Flushed int `json:"flushed"`
+}
+
+// FlushCache handles POST /admin/cache/flush, dropping every cached company
+// so reads go to the database, e.g. after a manual data fix
+func (h *AdminHandler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	flushed, err := h.cache.FlushCache(r.Context())
+	if err != nil {
+		log.Printf("Cache flush failed after %d entries: %v", flushed, err)
+		respondError(w, r, "cache flush failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Flushed %d cache entries via admin endpoint", flushed)
+	respondJSON(w, FlushCacheResponse{Flushed: flushed}, http.StatusOK)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMigrator is a mock implementation of Migrator
+type MockMigrator struct {
+	mock.Mock
+}
+
+func (m *MockMigrator) Migrate(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockMigrator) SchemaVersion(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func TestAdminHandler_Migrate(t *testing.T) {
+	t.Run("reports the resulting version", func(t *testing.T) {
+		migrator := new(MockMigrator)
+		h := NewAdminHandler(migrator, nil, nil)
+
+		migrator.On("Migrate", mock.Anything).Return(nil)
+		migrator.On("SchemaVersion", mock.Anything).Return(2, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/migrate", nil)
+		rec := httptest.NewRecorder()
+
+		h.Migrate(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"version":2}`, rec.Body.String())
+	})
+
+	t.Run("migration failure", func(t *testing.T) {
+		migrator := new(MockMigrator)
+		h := NewAdminHandler(migrator, nil, nil)
+
+		migrator.On("Migrate", mock.Anything).Return(errors.New("syntax error"))
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/migrate", nil)
+		rec := httptest.NewRecorder()
+
+		h.Migrate(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "syntax error")
+		migrator.AssertNotCalled(t, "SchemaVersion", mock.Anything)
+	})
+}
+
+// fakeEventSwitch records the event emission state
+type fakeEventSwitch struct {
+	enabled bool
+}
+
+func (f *fakeEventSwitch) EventsEnabled() bool { return f.enabled }
+
+func (f *fakeEventSwitch) SetEventsEnabled(enabled bool) { f.enabled = enabled }
+
+func TestAdminHandler_Events(t *testing.T) {
+	t.Run("reports the current state", func(t *testing.T) {
+		h := NewAdminHandler(nil, &fakeEventSwitch{enabled: true}, nil)
+
+		rec := httptest.NewRecorder()
+		h.Events(rec, httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"enabled":true}`, rec.Body.String())
+	})
+
+	t.Run("toggles emission", func(t *testing.T) {
+		events := &fakeEventSwitch{enabled: true}
+		h := NewAdminHandler(nil, events, nil)
+
+		rec := httptest.NewRecorder()
+		h.SetEvents(rec, httptest.NewRequest(http.MethodPut, "/admin/events", strings.NewReader(`{"enabled":false}`)))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"enabled":false}`, rec.Body.String())
+		assert.False(t, events.enabled)
+	})
+
+	t.Run("rejects a body without enabled", func(t *testing.T) {
+		for _, body := range []string{`{}`, `{"enabled":"no"}`, `not json`} {
+			events := &fakeEventSwitch{enabled: true}
+			h := NewAdminHandler(nil, events, nil)
+
+			rec := httptest.NewRecorder()
+			h.SetEvents(rec, httptest.NewRequest(http.MethodPut, "/admin/events", strings.NewReader(body)))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+			assert.True(t, events.enabled, body)
+		}
+	})
+}
+
+// fakeCacheFlusher returns a fixed flush result
+type fakeCacheFlusher struct {
+	flushed int
+	err     error
+}
+
+func (f *fakeCacheFlusher) FlushCache(ctx context.Context) (int, error) {
+	return f.flushed, f.err
+}
+
+func TestAdminHandler_FlushCache(t *testing.T) {
+	t.Run("reports the number of entries flushed", func(t *testing.T) {
+		h := NewAdminHandler(nil, nil, &fakeCacheFlusher{flushed: 7})
+
+		rec := httptest.NewRecorder()
+		h.FlushCache(rec, httptest.NewRequest(http.MethodPost, "/admin/cache/flush", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"flushed":7}`, rec.Body.String())
+	})
+
+	t.Run("flush failure is a server error", func(t *testing.T) {
+		h := NewAdminHandler(nil, nil, &fakeCacheFlusher{err: errors.New("connection reset")})
+
+		rec := httptest.NewRecorder()
+		h.FlushCache(rec, httptest.NewRequest(http.MethodPost, "/admin/cache/flush", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
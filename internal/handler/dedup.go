@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// dedupCache remembers recent creates by caller and payload so an accidental
+// double submit returns the first company instead of a 409. Entries live
+// for one window and are kept in memory, per replica.
+type dedupCache struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// order holds entries oldest first; every entry lives for the same
+	// window, so that is also expiry order
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key     string
+	id      uuid.UUID
+	expires time.Time
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		window:  window,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// newDedupKey identifies a create by who sent it, see middleware.ClientID,
+// and its exact body
+func newDedupKey(clientID string, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(clientID))
+	sum.Write([]byte{0})
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// lookup returns the company created for key within the window
+func (d *dedupCache) lookup(key string) (uuid.UUID, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[key]
+	if !ok {
+		return uuid.Nil, false
+	}
+	e := el.Value.(*dedupEntry)
+	if !d.now().Before(e.expires) {
+		d.remove(el)
+		return uuid.Nil, false
+	}
+	return e.id, true
+}
+
+// store records the company created for key, dropping expired entries from
+// the front of the list
+func (d *dedupCache) store(key string, id uuid.UUID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for el := d.order.Front(); el != nil && !now.Before(el.Value.(*dedupEntry).expires); el = d.order.Front() {
+		d.remove(el)
+	}
+
+	e := &dedupEntry{key: key, id: id, expires: now.Add(d.window)}
+	if el, ok := d.entries[key]; ok {
+		el.Value = e
+		d.order.MoveToBack(el)
+		return
+	}
+	d.entries[key] = d.order.PushBack(e)
+}
+
+// remove drops an element from the list and index. Callers must hold mu.
+func (d *dedupCache) remove(el *list.Element) {
+	d.order.Remove(el)
+	delete(d.entries, el.Value.(*dedupEntry).key)
+}
@@ -1,25 +1,80 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// DefaultReadinessTimeout bounds the readiness checks unless
+// WithReadinessTimeout overrides it
+const DefaultReadinessTimeout = 2 * time.Second
+
+// HealthChecker reports whether a dependency is currently usable
+type HealthChecker interface {
+	Healthy() bool
+}
+
+// Pinger verifies a database connection; *sql.DB implements it
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PoolStater reports connection pool statistics; *sql.DB implements it
+type PoolStater interface {
+	Stats() sql.DBStats
+}
+
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	db *sql.DB
+	db       Pinger
+	producer HealthChecker
+	timeout  time.Duration
 }
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler(db *sql.DB) *HealthHandler {
-	return &HealthHandler{db: db}
+// HealthOption configures a HealthHandler
+type HealthOption func(*HealthHandler)
+
+// WithReadinessTimeout bounds the dependency checks of the readiness probe,
+// so a hung database fails the probe instead of hanging it. Non-positive
+// values keep DefaultReadinessTimeout.
+func WithReadinessTimeout(timeout time.Duration) HealthOption {
+	return func(h *HealthHandler) {
+		if timeout > 0 {
+			h.timeout = timeout
+		}
+	}
+}
+
+// NewHealthHandler creates a new health handler. producer may be nil when
+// the event producer does not report its health.
+func NewHealthHandler(db Pinger, producer HealthChecker, opts ...HealthOption) *HealthHandler {
+	h := &HealthHandler{db: db, producer: producer, timeout: DefaultReadinessTimeout}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status   string            `json:"status"`
 	Services map[string]string `json:"services,omitempty"`
+	// Pools holds connection pool statistics by service, for verbose
+	// readiness probes only
+	Pools map[string]PoolStats `json:"pools,omitempty"`
+}
+
+// PoolStats is a snapshot of a connection pool
+type PoolStats struct {
+	Open      int   `json:"open"`
+	InUse     int   `json:"inUse"`
+	Idle      int   `json:"idle"`
+	WaitCount int64 `json:"waitCount"`
 }
 
 // Live handles GET /health/live - basic liveness check
@@ -29,25 +84,67 @@ func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
 }
 
-// Ready handles GET /health/ready - readiness check including dependencies
+// Ready handles GET /health/ready - readiness check including dependencies.
+// ?verbose=true adds connection pool statistics.
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	verbose := false
+	if raw := r.URL.Query().Get("verbose"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondQueryParamError(w, r, "verbose")
+			return
+		}
+		verbose = v
+	}
+
 	services := make(map[string]string)
 	status := http.StatusOK
 	overallStatus := "ok"
 
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
 	// Check database connectivity
-	if err := h.db.PingContext(r.Context()); err != nil {
-		services["database"] = "unhealthy: " + err.Error()
+	if err := h.db.PingContext(ctx); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			services["database"] = "unhealthy: timeout"
+		} else {
+			services["database"] = "unhealthy: " + err.Error()
+		}
 		status = http.StatusServiceUnavailable
 		overallStatus = "unhealthy"
 	} else {
 		services["database"] = "healthy"
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(HealthResponse{
+	// Check the event producer
+	if h.producer != nil {
+		if h.producer.Healthy() {
+			services["events"] = "healthy"
+		} else {
+			services["events"] = "unhealthy: repeated publish failures"
+			status = http.StatusServiceUnavailable
+			overallStatus = "unhealthy"
+		}
+	}
+
+	response := HealthResponse{
 		Status:   overallStatus,
 		Services: services,
-	})
+	}
+	if stater, ok := h.db.(PoolStater); ok && verbose {
+		stats := stater.Stats()
+		response.Pools = map[string]PoolStats{
+			"database": {
+				Open:      stats.OpenConnections,
+				InUse:     stats.InUse,
+				Idle:      stats.Idle,
+				WaitCount: stats.WaitCount,
+			},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePinger returns err, or blocks until the context ends when block is set
+type fakePinger struct {
+	block bool
+	err   error
+}
+
+func (p *fakePinger) PingContext(ctx context.Context) error {
+	if p.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+// statsPinger is a healthy database that also reports pool statistics
+type statsPinger struct {
+	fakePinger
+	stats sql.DBStats
+}
+
+func (p *statsPinger) Stats() sql.DBStats { return p.stats }
+
+type fakeHealthChecker bool
+
+func (c fakeHealthChecker) Healthy() bool { return bool(c) }
+
+func TestHealthHandler_Ready(t *testing.T) {
+	tests := []struct {
+		name       string
+		db         *fakePinger
+		producer   HealthChecker
+		wantStatus int
+		wantDB     string
+		wantEvents string
+	}{
+		{"healthy", &fakePinger{}, fakeHealthChecker(true), http.StatusOK, "healthy", "healthy"},
+		{"database down", &fakePinger{err: errors.New("connection refused")}, nil, http.StatusServiceUnavailable, "unhealthy: connection refused", ""},
+		{"database hangs", &fakePinger{block: true}, nil, http.StatusServiceUnavailable, "unhealthy: timeout", ""},
+		{"producer unhealthy", &fakePinger{}, fakeHealthChecker(false), http.StatusServiceUnavailable, "healthy", "unhealthy: repeated publish failures"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(tt.db, tt.producer, WithReadinessTimeout(20*time.Millisecond))
+
+			req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+			rec := httptest.NewRecorder()
+
+			start := time.Now()
+			h.Ready(rec, req)
+			assert.Less(t, time.Since(start), time.Second, "probe must not hang")
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			var resp HealthResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantDB, resp.Services["database"])
+			assert.Equal(t, tt.wantEvents, resp.Services["events"])
+		})
+	}
+}
+
+func TestHealthHandler_ReadyVerbose(t *testing.T) {
+	db := &statsPinger{stats: sql.DBStats{OpenConnections: 7, InUse: 5, Idle: 2, WaitCount: 13}}
+	h := NewHealthHandler(db, nil)
+
+	t.Run("verbose includes pool stats", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/health/ready?verbose=true", nil)
+		rec := httptest.NewRecorder()
+
+		h.Ready(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"status": "ok",
+			"services": {"database": "healthy"},
+			"pools": {"database": {"open": 7, "inUse": 5, "idle": 2, "waitCount": 13}}
+		}`, rec.Body.String())
+	})
+
+	t.Run("default output stays minimal", func(t *testing.T) {
+		for _, query := range []string{"", "?verbose=false"} {
+			req := httptest.NewRequest(http.MethodGet, "/health/ready"+query, nil)
+			rec := httptest.NewRecorder()
+
+			h.Ready(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code, query)
+			assert.JSONEq(t, `{"status":"ok","services":{"database":"healthy"}}`, rec.Body.String(), query)
+		}
+	})
+
+	t.Run("database without stats", func(t *testing.T) {
+		h := NewHealthHandler(&fakePinger{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/health/ready?verbose=true", nil)
+		rec := httptest.NewRecorder()
+
+		h.Ready(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "pools")
+	})
+
+	t.Run("invalid flag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/health/ready?verbose=maybe", nil)
+		rec := httptest.NewRecorder()
+
+		h.Ready(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid verbose parameter","code":"INVALID_QUERY_PARAM","param":"verbose"}`, rec.Body.String())
+	})
+}
@@ -2,11 +2,21 @@ package handler
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"html"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"xm-company-service/internal/core"
+	"xm-company-service/internal/middleware"
 	"xm-company-service/internal/service"
 
 	"github.com/go-chi/chi/v5"
@@ -15,83 +25,581 @@ import (
 
 // Handler handles HTTP requests for company operations
 type Handler struct {
-	svc *service.CompanyService
+	svc            *service.CompanyService
+	requiredFields []string
+	dedup          *dedupCache
+	warnSimilar    bool
+	typeLabels     map[core.CompanyType]string
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithRequiredFields makes Create reject bodies that omit any of fields.
+// Field names are the JSON names, see IsCreateField.
+func WithRequiredFields(fields []string) Option {
+	return func(h *Handler) {
+		h.requiredFields = fields
+	}
+}
+
+// WithDedupWindow makes Create answer an identical body from the same user
+// within window with the company the first request created, instead of a
+// 409. Zero disables it.
+func WithDedupWindow(window time.Duration) Option {
+	return func(h *Handler) {
+		if window > 0 {
+			h.dedup = newDedupCache(window)
+		}
+	}
+}
+
+// WithSimilarNameWarnings makes Create list existing companies with
+// near-duplicate names in a warning. The company is created regardless.
+func WithSimilarNameWarnings(enabled bool) Option {
+	return func(h *Handler) {
+		h.warnSimilar = enabled
+	}
 }
 
 // NewHandler creates a new HTTP handler
-func NewHandler(svc *service.CompanyService) *Handler {
-	return &Handler{svc: svc}
+func NewHandler(svc *service.CompanyService, opts ...Option) *Handler {
+	h := &Handler{svc: svc}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+	Param string `json:"param,omitempty"`
+}
+
+// codeInvalidQueryParam marks a malformed query parameter, named in Param
+const codeInvalidQueryParam = "INVALID_QUERY_PARAM"
+
+// Warning codes
+const (
+	// WarningSimilarNames lists near-duplicates of a created company's name
+	WarningSimilarNames = "SIMILAR_NAMES"
+)
+
+// Warning is advisory information about a request that succeeded
+type Warning struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Names   []string `json:"names,omitempty"`
+}
+
+// CreatedResponse is a created company with warnings about it
+type CreatedResponse struct {
+	CompanyResponse
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// IDResponse is the minimal representation returned for Prefer: return=minimal
+type IDResponse struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// CompanyResponse is the representation of a company in every response. It
+// adds derived, read-only fields that are computed per response and not
+// stored.
+type CompanyResponse struct {
+	XMLName xml.Name `json:"-" xml:"company"`
+	*core.Company
+	SizeCategory string `json:"sizeCategory" xml:"sizeCategory"`
+	// CompanyAgeDays is the number of whole days since CreatedAt, omitted
+	// when the creation time is unknown
+	CompanyAgeDays *int `json:"companyAgeDays,omitempty" xml:"companyAgeDays,omitempty"`
+}
+
+// Size categories by employee count
+const (
+	SizeSmall  = "small"  // fewer than 50 employees
+	SizeMedium = "medium" // 50 to 249 employees
+	SizeLarge  = "large"  // 250 employees or more
+)
+
+// newCompanyResponse wraps c with its derived fields
+func newCompanyResponse(c *core.Company) CompanyResponse {
+	resp := CompanyResponse{
+		Company:      c,
+		SizeCategory: sizeCategory(c.Employees),
+	}
+	if c.CreatedAt != nil {
+		days := companyAgeDays(*c.CreatedAt, time.Now())
+		resp.CompanyAgeDays = &days
+	}
+	return resp
+}
+
+// companyAgeDays returns the whole days from createdAt to now
+func companyAgeDays(createdAt, now time.Time) int {
+	if now.Before(createdAt) {
+		return 0
+	}
+	return int(now.Sub(createdAt) / (24 * time.Hour))
+}
+
+// newCompanyResponses wraps each company with its derived fields
+func newCompanyResponses(companies []*core.Company) []CompanyResponse {
+	resp := make([]CompanyResponse, len(companies))
+	for i, c := range companies {
+		resp[i] = newCompanyResponse(c)
+	}
+	return resp
 }
 
-// CreateRequest represents the request body for creating a company
+// sizeCategory classifies a company by headcount
+func sizeCategory(employees int) string {
+	switch {
+	case employees < 50:
+		return SizeSmall
+	case employees < 250:
+		return SizeMedium
+	default:
+		return SizeLarge
+	}
+}
+
+// CreateRequest represents the request body for creating a company.
+// Fields are pointers so an omitted field can be told apart from a zero value.
+// ID is optional and lets clients assign their own UUID.
 type CreateRequest struct {
-	Name        string           `json:"name"`
-	Description *string          `json:"description,omitempty"`
-	Employees   int              `json:"employees"`
-	Registered  bool             `json:"registered"`
-	Type        core.CompanyType `json:"type"`
+	ID          *string           `json:"id,omitempty"`
+	Name        *string           `json:"name"`
+	Description *string           `json:"description,omitempty"`
+	Employees   *int              `json:"employees"`
+	Registered  *jsonBool         `json:"registered"`
+	Type        *core.CompanyType `json:"type"`
+}
+
+// jsonBool is a JSON boolean that keeps a string sent in its place, so the
+// service can decide whether to accept it, see service.WithLenientBooleans
+type jsonBool struct {
+	Value bool
+	// String is set when the body sent a string instead of a boolean
+	String *string
+}
+
+func (b *jsonBool) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		b.String = &s
+		return nil
+	}
+	return json.Unmarshal(data, &b.Value)
+}
+
+// present reports which fields the request body set, by JSON name
+func (req *CreateRequest) present() map[string]bool {
+	return map[string]bool{
+		"name":        req.Name != nil,
+		"description": req.Description != nil,
+		"employees":   req.Employees != nil,
+		"registered":  req.Registered != nil,
+		"type":        req.Type != nil,
+	}
+}
+
+// company converts the request into a company, leaving omitted fields zero
+func (req *CreateRequest) company() *core.Company {
+	c := &core.Company{Description: req.Description}
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.Employees != nil {
+		c.Employees = *req.Employees
+	}
+	if req.Registered != nil {
+		c.Registered = req.Registered.Value
+	}
+	if req.Type != nil {
+		c.Type = *req.Type
+	}
+	return c
+}
+
+// IsCreateField reports whether name is a field accepted by Create
+func IsCreateField(name string) bool {
+	_, ok := (&CreateRequest{}).present()[name]
+	return ok
 }
 
 // Create handles POST /companies
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, r, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
 	var req CreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, "invalid JSON body", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &req); err != nil {
+		// An unknown type is reported as such rather than as bad JSON
+		var validationErr *core.ValidationError
+		if errors.As(err, &validationErr) {
+			handleServiceError(w, r, validationErr)
+			return
+		}
+		respondError(w, r, "invalid JSON body", http.StatusBadRequest)
 		return
 	}
+	if req.Registered != nil && req.Registered.String != nil {
+		registered, err := h.svc.ParseBool("registered", *req.Registered.String)
+		if err != nil {
+			handleServiceError(w, r, err)
+			return
+		}
+		req.Registered.Value = registered
+	}
 
-	company := &core.Company{
-		Name:        req.Name,
-		Description: req.Description,
-		Employees:   req.Employees,
-		Registered:  req.Registered,
-		Type:        req.Type,
+	// A double submit gets the company the first request created, marked
+	// as a replay. Content-Location says the body is that company as stored.
+	var dedupKey string
+	if h.dedup != nil {
+		dedupKey = newDedupKey(middleware.ClientID(r), body)
+		if id, ok := h.dedup.lookup(dedupKey); ok {
+			if existing, err := h.svc.Get(r.Context(), id); err == nil {
+				location := "/companies/" + existing.ID.String()
+				w.Header().Set("Location", location)
+				if !prefersMinimal(r) {
+					w.Header().Set("Content-Location", location)
+				}
+				w.Header().Set("Idempotency-Replayed", "true")
+				respondCompany(w, r, existing, http.StatusCreated)
+				return
+			}
+		}
 	}
 
-	created, err := h.svc.Create(r.Context(), company)
+	present := req.present()
+	for _, field := range h.requiredFields {
+		if !present[field] {
+			respondError(w, r, field+" is required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	company := req.company()
+	if req.ID != nil {
+		id, ok := parseID(w, r, *req.ID)
+		if !ok {
+			return
+		}
+		if id == uuid.Nil {
+			respondInvalidID(w, r, *req.ID)
+			return
+		}
+		company.ID = id
+	}
+
+	var omitted []string
+	for field, ok := range present {
+		if !ok {
+			omitted = append(omitted, field)
+		}
+	}
+	opts := []service.CreateOption{service.Omitted(omitted...)}
+	if prefersReturnExisting(r) {
+		opts = append(opts, service.ReturnExisting())
+	}
+	waitForEvent := prefersWaitForEvent(r)
+	if waitForEvent {
+		opts = append(opts, service.WaitForEvent())
+	}
+
+	created, existed, err := h.svc.Create(r.Context(), company, opts...)
+	var eventErr *service.EventError
+	if errors.As(err, &eventErr) {
+		// Stored, but the CompanyCreated event was not acknowledged
+		h.remember(dedupKey, eventErr.Company)
+		w.Header().Set("Location", "/companies/"+eventErr.Company.ID.String())
+		w.Header().Add("Preference-Applied", preferWaitForEvent)
+		respondCompany(w, r, eventErr.Company, http.StatusAccepted)
+		return
+	}
 	if err != nil {
-		handleServiceError(w, err)
+		handleServiceError(w, r, err)
+		return
+	}
+	if waitForEvent && !existed {
+		w.Header().Add("Preference-Applied", preferWaitForEvent)
+	}
+
+	w.Header().Set("Location", "/companies/"+created.ID.String())
+	if existed {
+		// The name was taken and the client asked for the existing company
+		w.Header().Add("Preference-Applied", preferResolution+"="+preferResolutionReturnExisting)
+		respondCompany(w, r, created, http.StatusOK)
 		return
 	}
+	h.remember(dedupKey, created)
+	if !prefersMinimal(r) {
+		if warnings := h.createWarnings(r, created); len(warnings) > 0 {
+			respondJSON(w, CreatedResponse{CompanyResponse: newCompanyResponse(created), Warnings: warnings}, http.StatusCreated)
+			return
+		}
+	}
+	respondCompany(w, r, created, http.StatusCreated)
+}
 
-	respondJSON(w, created, http.StatusCreated)
+// createWarnings flags a created company whose name is close to an existing
+// one. Failing to check is logged, not reported: the company exists.
+func (h *Handler) createWarnings(r *http.Request, c *core.Company) []Warning {
+	if !h.warnSimilar {
+		return nil
+	}
+	names, err := h.svc.SimilarNames(r.Context(), c)
+	if err != nil {
+		log.Printf("Warning: similar name check failed for %s: %v", c.ID, err)
+		return nil
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return []Warning{{
+		Code:    WarningSimilarNames,
+		Message: "companies with similar names exist",
+		Names:   names,
+	}}
+}
+
+// remember records a created company for double-submit detection
+func (h *Handler) remember(key string, c *core.Company) {
+	if h.dedup != nil {
+		h.dedup.store(key, c.ID)
+	}
 }
 
-// Get handles GET /companies/{id}
+// Get handles GET /companies/{id}. ?expandType=true returns the type as
+// an object with its code and display label, see WithTypeLabels.
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		respondError(w, "invalid UUID format", http.StatusBadRequest)
+	id, ok := parseID(w, r, chi.URLParam(r, "id"))
+	if !ok {
 		return
 	}
 
+	expand := false
+	if raw := r.URL.Query().Get("expandType"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondQueryParamError(w, r, "expandType")
+			return
+		}
+		expand = v
+	}
+
 	company, err := h.svc.Get(r.Context(), id)
 	if err != nil {
-		handleServiceError(w, err)
+		handleServiceError(w, r, err)
+		return
+	}
+
+	var resp interface{} = newCompanyResponse(company)
+	if expand {
+		resp = ExpandedCompanyResponse{
+			CompanyResponse: newCompanyResponse(company),
+			Type:            h.expandType(company.Type),
+		}
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if acceptsXML(r) {
+		respondXML(w, resp, http.StatusOK)
+		return
+	}
+	respondJSON(w, resp, http.StatusOK)
+}
+
+// Aggregate handles GET /companies/aggregate?metric=employees[&type=...]
+func (h *Handler) Aggregate(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("metric") != "employees" {
+		respondQueryParamError(w, r, "metric")
+		return
+	}
+	companyType := core.CompanyType(query.Get("type"))
+	if companyType != "" && !companyType.IsValid() {
+		respondQueryParamError(w, r, "type")
+		return
+	}
+
+	aggregates, err := h.svc.EmployeeAggregates(r.Context(), companyType)
+	if err != nil {
+		handleServiceError(w, r, err)
 		return
 	}
 
-	respondJSON(w, company, http.StatusOK)
+	respondJSON(w, aggregates, http.StatusOK)
+}
+
+// Count handles HEAD /companies[?type=...][&exactCount=false], reporting
+// the number of companies in X-Total-Count without a body. With
+// exactCount=false a large table is estimated instead of counted, flagged
+// by X-Total-Count-Estimated.
+func (h *Handler) Count(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	companyType := core.CompanyType(query.Get("type"))
+	if companyType != "" && !companyType.IsValid() {
+		respondQueryParamError(w, r, "type")
+		return
+	}
+	exactCount := true
+	if raw := query.Get("exactCount"); raw != "" {
+		var err error
+		if exactCount, err = strconv.ParseBool(raw); err != nil {
+			respondQueryParamError(w, r, "exactCount")
+			return
+		}
+	}
+
+	var count int
+	var err error
+	if exactCount {
+		count, err = h.svc.Count(r.Context(), companyType)
+	} else {
+		count, exactCount, err = h.svc.EstimateCount(r.Context(), companyType)
+	}
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if !exactCount {
+		w.Header().Set("X-Total-Count-Estimated", "true")
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(count))
+	w.WriteHeader(http.StatusOK)
+}
+
+// DistributionResponse is a histogram of one company field
+type DistributionResponse struct {
+	Field   string                `json:"field"`
+	Buckets []core.EmployeeBucket `json:"buckets"`
+}
+
+// Distribution handles GET /companies/distribution?field=employees&buckets=0,10,50
+func (h *Handler) Distribution(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("field") != "employees" {
+		respondQueryParamError(w, r, "field")
+		return
+	}
+	var bounds []int
+	for _, raw := range strings.Split(query.Get("buckets"), ",") {
+		bound, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			respondQueryParamError(w, r, "buckets")
+			return
+		}
+		bounds = append(bounds, bound)
+	}
+
+	buckets, err := h.svc.EmployeeDistribution(r.Context(), bounds)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	respondJSON(w, DistributionResponse{Field: "employees", Buckets: buckets}, http.StatusOK)
+}
+
+// AutocompleteResponse lists name suggestions
+type AutocompleteResponse struct {
+	Companies []*core.CompanyName `json:"companies"`
+}
+
+// Autocomplete handles GET /companies/autocomplete?prefix=...[&limit=...]
+func (h *Handler) Autocomplete(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, ok := intQueryParam(query, "limit", service.DefaultAutocompleteLimit)
+	if !ok || limit < 1 || limit > service.MaxAutocompleteLimit {
+		respondQueryParamError(w, r, "limit")
+		return
+	}
+
+	names, err := h.svc.Autocomplete(r.Context(), query.Get("prefix"), limit)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	respondJSON(w, AutocompleteResponse{Companies: names}, http.StatusOK)
+}
+
+// SearchResponse is a page of search results
+type SearchResponse struct {
+	Companies []CompanyResponse `json:"companies"`
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+}
+
+// Search handles GET /companies/search?q=...[&limit=...&offset=...]
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, ok := intQueryParam(query, "limit", service.DefaultSearchLimit)
+	if !ok || limit < 1 || limit > service.MaxSearchLimit {
+		respondQueryParamError(w, r, "limit")
+		return
+	}
+	offset, ok := intQueryParam(query, "offset", 0)
+	if !ok || offset < 0 {
+		respondQueryParamError(w, r, "offset")
+		return
+	}
+
+	companies, err := h.svc.SearchByName(r.Context(), query.Get("q"), limit, offset)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	respondJSON(w, SearchResponse{Companies: newCompanyResponses(companies), Limit: limit, Offset: offset}, http.StatusOK)
+}
+
+// intQueryParam parses an integer query parameter, returning def when it is
+// absent and false when it is not an integer
+func intQueryParam(query url.Values, key string, def int) (int, bool) {
+	raw := query.Get(key)
+	if raw == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(raw)
+	return n, err == nil
 }
 
 // Patch handles PATCH /companies/{id}
 func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		respondError(w, "invalid UUID format", http.StatusBadRequest)
+	id, ok := parseID(w, r, chi.URLParam(r, "id"))
+	if !ok {
 		return
 	}
 
+	// UseNumber keeps employees exact instead of rounding it through float64
 	var updates map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		respondError(w, "invalid JSON body", http.StatusBadRequest)
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	err := dec.Decode(&updates)
+	switch {
+	case errors.Is(err, io.EOF):
+		// Empty body: fall back to simple fields passed as query parameters
+		updates, err = queryUpdates(r.URL.Query())
+		if err != nil {
+			respondError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case err != nil:
+		respondError(w, r, "invalid JSON body", http.StatusBadRequest)
+		return
+	case len(r.URL.Query()) > 0:
+		respondError(w, r, "query parameters cannot be combined with a JSON body", http.StatusBadRequest)
 		return
 	}
 
@@ -99,72 +607,253 @@ func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
 	delete(updates, "id")
 
 	if len(updates) == 0 {
-		respondError(w, "no fields to update", http.StatusBadRequest)
+		respondError(w, r, "no fields to update", http.StatusBadRequest)
 		return
 	}
 
-	updated, err := h.svc.Patch(r.Context(), id, updates)
+	updated, changed, err := h.svc.Patch(r.Context(), id, updates)
 	if err != nil {
-		handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
+	if !changed {
+		w.Header().Set("X-Unchanged", "true")
+	}
 
-	respondJSON(w, updated, http.StatusOK)
+	respondCompany(w, r, updated, http.StatusOK)
 }
 
 // Delete handles DELETE /companies/{id}
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		respondError(w, "invalid UUID format", http.StatusBadRequest)
+	id, ok := parseID(w, r, chi.URLParam(r, "id"))
+	if !ok {
 		return
 	}
 
-	err = h.svc.Delete(r.Context(), id)
-	if err != nil {
-		handleServiceError(w, err)
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		handleServiceError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handleServiceError maps service errors to HTTP status codes
-func handleServiceError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, core.ErrNotFound):
-		respondError(w, err.Error(), http.StatusNotFound)
-	case errors.Is(err, core.ErrDuplicateName):
-		respondError(w, err.Error(), http.StatusConflict)
-	default:
-		// Check for validation errors
-		errMsg := err.Error()
-		if isValidationError(errMsg) {
-			respondError(w, errMsg, http.StatusBadRequest)
+// BulkPatchItem is one entry of a PATCH /companies body
+type BulkPatchItem struct {
+	ID      string                 `json:"id"`
+	Updates map[string]interface{} `json:"updates"`
+}
+
+// BulkPatchResult reports one item of a bulk patch with the status it
+// would have received as a single PATCH
+type BulkPatchResult struct {
+	ID        string           `json:"id"`
+	Status    int              `json:"status"`
+	Unchanged bool             `json:"unchanged,omitempty"`
+	Company   *CompanyResponse `json:"company,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Code      string           `json:"code,omitempty"`
+}
+
+// BulkPatchResponse lists the item results in request order
+type BulkPatchResponse struct {
+	Atomic  bool              `json:"atomic"`
+	Results []BulkPatchResult `json:"results"`
+}
+
+// PatchMany handles PATCH /companies[?atomic=false], applying an array of
+// {id, updates} items. By default the batch is all or nothing; with
+// atomic=false each item is applied on its own. The response is 200 when
+// every item succeeded and 207 Multi-Status otherwise.
+func (h *Handler) PatchMany(w http.ResponseWriter, r *http.Request) {
+	atomic := true
+	if raw := r.URL.Query().Get("atomic"); raw != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(raw); err != nil {
+			respondQueryParamError(w, r, "atomic")
+			return
+		}
+	}
+
+	// UseNumber keeps employees exact instead of rounding it through float64
+	var body []BulkPatchItem
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		respondError(w, r, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	items := make([]service.PatchItem, len(body))
+	for i, item := range body {
+		id, ok := parseID(w, r, item.ID)
+		if !ok {
+			return
+		}
+		// Don't allow updating ID
+		delete(item.Updates, "id")
+		if len(item.Updates) == 0 {
+			respondError(w, r, "no fields to update for company "+id.String(), http.StatusBadRequest)
 			return
 		}
-		log.Printf("Internal error: %v", err)
-		respondError(w, "internal server error", http.StatusInternalServerError)
+		items[i] = service.PatchItem{ID: id, Updates: item.Updates}
+	}
+
+	results, err := h.svc.PatchMany(r.Context(), items, atomic)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	resp := BulkPatchResponse{Atomic: atomic, Results: make([]BulkPatchResult, len(results))}
+	status := http.StatusOK
+	for i, result := range results {
+		out := BulkPatchResult{ID: result.ID.String(), Status: http.StatusOK}
+		if result.Err != nil {
+			var errBody ErrorResponse
+			out.Status, errBody = itemError(result.Err)
+			out.Error, out.Code = errBody.Error, errBody.Code
+			status = http.StatusMultiStatus
+		} else {
+			company := newCompanyResponse(result.Company)
+			out.Company = &company
+			out.Unchanged = !result.Changed
+		}
+		resp.Results[i] = out
 	}
+	respondJSON(w, resp, status)
 }
 
-// isValidationError checks if the error message indicates a validation error
-func isValidationError(msg string) bool {
-	validationPrefixes := []string{
-		"name is required",
-		"name must be",
-		"description must be",
-		"employees cannot be",
-		"invalid company type",
-		"registered",
+// queryUpdates converts PATCH query parameters into an updates map.
+// Only the simple company fields are accepted; values are converted to the
+// types a JSON body would carry so the same validation applies.
+func queryUpdates(query url.Values) (map[string]interface{}, error) {
+	updates := make(map[string]interface{}, len(query))
+	for key, values := range query {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("query parameter %s must be given exactly once", key)
+		}
+		value := values[0]
+
+		switch key {
+		case "name", "description", "type":
+			updates[key] = value
+		case "employees":
+			employees, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.New("employees must be a number")
+			}
+			updates[key] = employees
+		case "registered":
+			registered, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, errors.New("registered must be a boolean")
+			}
+			updates[key] = registered
+		default:
+			return nil, fmt.Errorf("unknown query parameter: %s", key)
+		}
+	}
+	return updates, nil
+}
+
+// errorResponse describes how a service error is reported to clients
+type errorResponse struct {
+	err     error
+	status  int
+	code    string
+	headers map[string]string
+}
+
+// defaultRetryAfter is sent with 503 responses whose error has no retry hint
+const defaultRetryAfter = 30 * time.Second
+
+// readOnlyRetryAfter is sent while the database is read-only; a failover
+// usually completes within a few seconds
+const readOnlyRetryAfter = 5 * time.Second
+
+// errorResponses maps service errors to HTTP responses, checked in order
+var errorResponses = []errorResponse{
+	{err: core.ErrNotFound, status: http.StatusNotFound},
+	{err: core.ErrDuplicateName, status: http.StatusConflict},
+	{err: core.ErrDuplicateID, status: http.StatusConflict},
+	{err: core.ErrQuotaExceeded, status: http.StatusForbidden, code: "QUOTA_EXCEEDED"},
+	{err: core.ErrBatchAborted, status: http.StatusFailedDependency, code: "BATCH_ABORTED"},
+	{err: core.ErrDescriptionTooLong, status: http.StatusUnprocessableEntity, code: "DESCRIPTION_TOO_LONG"},
+	{err: core.ErrUnavailable, status: http.StatusServiceUnavailable, headers: map[string]string{
+		"Retry-After": retryAfterSeconds(defaultRetryAfter),
+	}},
+	{err: core.ErrReadOnly, status: http.StatusServiceUnavailable, headers: map[string]string{
+		"Retry-After": retryAfterSeconds(readOnlyRetryAfter),
+	}},
+}
+
+// handleServiceError maps service errors to HTTP status codes. Errors
+// wrapped with core.WithRetryAfter also get a Retry-After header.
+func handleServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	if resp, ok := findErrorResponse(err); ok {
+		for key, value := range resp.headers {
+			w.Header().Set(key, value)
+		}
+		var retry *core.RetryAfterError
+		if errors.As(err, &retry) {
+			w.Header().Set("Retry-After", retryAfterSeconds(retry.Delay))
+		}
+		respondErrorBody(w, r, ErrorResponse{Error: err.Error(), Code: resp.code}, resp.status)
+		return
+	}
+
+	var validationErr *core.ValidationError
+	if errors.As(err, &validationErr) {
+		respondError(w, r, validationErr.Error(), http.StatusBadRequest)
+		return
 	}
-	for _, prefix := range validationPrefixes {
-		if len(msg) >= len(prefix) && msg[:len(prefix)] == prefix {
-			return true
+	log.Printf("Internal error: %v", err)
+	respondError(w, r, "internal server error", http.StatusInternalServerError)
+}
+
+// findErrorResponse returns the first errorResponses entry matching err
+func findErrorResponse(err error) (errorResponse, bool) {
+	for _, resp := range errorResponses {
+		if errors.Is(err, resp.err) {
+			return resp, true
 		}
 	}
-	return false
+	return errorResponse{}, false
+}
+
+// itemError reports a batch item's error with the status and body
+// handleServiceError would use for the same error in a single request
+func itemError(err error) (int, ErrorResponse) {
+	if resp, ok := findErrorResponse(err); ok {
+		return resp.status, ErrorResponse{Error: err.Error(), Code: resp.code}
+	}
+	var validationErr *core.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest, ErrorResponse{Error: validationErr.Error()}
+	}
+	log.Printf("Internal error: %v", err)
+	return http.StatusInternalServerError, ErrorResponse{Error: "internal server error"}
+}
+
+// retryAfterSeconds formats d as a Retry-After delay, rounding up
+func retryAfterSeconds(d time.Duration) string {
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs < 0 {
+		secs = 0
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
+// respondCompany writes the company, or only its ID when the client
+// sent Prefer: return=minimal
+func respondCompany(w http.ResponseWriter, r *http.Request, c *core.Company, status int) {
+	if prefersMinimal(r) {
+		w.Header().Add("Preference-Applied", "return=minimal")
+		respondJSON(w, IDResponse{ID: c.ID}, status)
+		return
+	}
+	respondJSON(w, newCompanyResponse(c), status)
 }
 
 // respondJSON writes a JSON response
@@ -176,9 +865,107 @@ func respondJSON(w http.ResponseWriter, data interface{}, status int) {
 	}
 }
 
+// respondXML writes an XML response
+func respondXML(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
 // respondError writes an error response
-func respondError(w http.ResponseWriter, message string, status int) {
-	w.Header().Set("Content-Type", "application/json")
+func respondError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	respondErrorBody(w, r, ErrorResponse{Error: message}, status)
+}
+
+// respondErrorBody writes body as JSON, or as a minimal HTML page for
+// browsers that prefer text/html
+func respondErrorBody(w http.ResponseWriter, r *http.Request, body ErrorResponse, status int) {
+	w.Header().Add("Vary", "Accept")
+	if !acceptsHTML(r) {
+		respondJSON(w, body, status)
+		return
+	}
+
+	title := html.EscapeString(fmt.Sprintf("%d %s", status, http.StatusText(status)))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body><h1>%s</h1><p>%s</p></body></html>\n",
+		title, title, html.EscapeString(body.Error))
+}
+
+// allowMethods are the methods MethodNotAllowed may list in Allow, in order
+var allowMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// MethodNotAllowed answers a request whose path is routed for other
+// methods: 405 with a METHOD_NOT_ALLOWED error and an Allow header listing
+// the methods routes serves for the path, as RFC 9110 requires. Install it
+// with chi.Mux.MethodNotAllowed on the top-level router.
+func MethodNotAllowed(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+		// A slashed path only gets here when trailing slashes are stripped
+		if len(path) > 1 {
+			path = strings.TrimSuffix(path, "/")
+		}
+
+		var allowed []string
+		for _, method := range allowMethods {
+			if routes.Match(chi.NewRouteContext(), method, path) {
+				allowed = append(allowed, method)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		respondErrorBody(w, r, ErrorResponse{Error: "method not allowed", Code: "METHOD_NOT_ALLOWED"}, http.StatusMethodNotAllowed)
+	}
+}
+
+// maxEchoedIDLength bounds how much of a malformed ID is echoed back
+const maxEchoedIDLength = 40
+
+// parseID parses a company ID, writing a 400 INVALID_ID error when raw is
+// not a UUID
+func parseID(w http.ResponseWriter, r *http.Request, raw string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		respondInvalidID(w, r, raw)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// respondInvalidID writes a 400 that echoes raw, truncated and with
+// non-printable characters replaced, next to the expected format
+func respondInvalidID(w http.ResponseWriter, r *http.Request, raw string) {
+	echoed := strings.Map(func(c rune) rune {
+		if !unicode.IsPrint(c) {
+			return '?'
+		}
+		return c
+	}, strings.ToValidUTF8(raw, "?"))
+	if runes := []rune(echoed); len(runes) > maxEchoedIDLength {
+		echoed = string(runes[:maxEchoedIDLength]) + "..."
+	}
+
+	respondErrorBody(w, r, ErrorResponse{
+		Error: fmt.Sprintf("invalid UUID format: expected a canonical UUID, got '%s'", echoed),
+		Code:  "INVALID_ID",
+	}, http.StatusBadRequest)
+}
+
+// respondQueryParamError writes a 400 naming the malformed query parameter
+// so clients can attach the error to the matching form field
+func respondQueryParamError(w http.ResponseWriter, r *http.Request, param string) {
+	respondErrorBody(w, r, ErrorResponse{
+		Error: "invalid " + param + " parameter",
+		Code:  codeInvalidQueryParam,
+		Param: param,
+	}, http.StatusBadRequest)
 }
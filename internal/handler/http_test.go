@@ -4,11 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"xm-company-service/internal/core"
+	"xm-company-service/internal/middleware"
 	"xm-company-service/internal/service"
 
 	"github.com/go-chi/chi/v5"
@@ -49,11 +55,66 @@ func (m *MockRepository) Update(ctx context.Context, company *core.Company) erro
 	return args.Error(0)
 }
 
+func (m *MockRepository) UpdateMany(ctx context.Context, companies []*core.Company) error {
+	args := m.Called(ctx, companies)
+	return args.Error(0)
+}
+
 func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockRepository) EmployeeAggregates(ctx context.Context, companyType core.CompanyType) (*core.EmployeeAggregates, error) {
+	args := m.Called(ctx, companyType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*core.EmployeeAggregates), args.Error(1)
+}
+
+func (m *MockRepository) SearchByName(ctx context.Context, q string, limit, offset int) ([]*core.Company, error) {
+	args := m.Called(ctx, q, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.Company), args.Error(1)
+}
+
+func (m *MockRepository) EmployeeDistribution(ctx context.Context, bounds []int) ([]core.EmployeeBucket, error) {
+	args := m.Called(ctx, bounds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]core.EmployeeBucket), args.Error(1)
+}
+
+func (m *MockRepository) FindSimilarNames(ctx context.Context, name string) ([]*core.CompanyName, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.CompanyName), args.Error(1)
+}
+
+func (m *MockRepository) NamePrefixSearch(ctx context.Context, prefix string, limit int) ([]*core.CompanyName, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.CompanyName), args.Error(1)
+}
+
+func (m *MockRepository) Count(ctx context.Context, companyType core.CompanyType) (int, error) {
+	args := m.Called(ctx, companyType)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ApproxCount(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
 // MockEventProducer for testing
 type MockEventProducer struct {
 	mock.Mock
@@ -84,113 +145,1590 @@ func TestHandler_Create(t *testing.T) {
 		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
 		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
 
-		body := `{"name":"TestCo","employees":10,"registered":true,"type":"Corporations"}`
-		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
-		req.Header.Set("Content-Type", "application/json")
+		body := `{"name":"TestCo","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response core.Company
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "TestCo", response.Name)
+		assert.NotEqual(t, uuid.Nil, response.ID)
+	})
+
+	t.Run("response carries the derived fields", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		repo.On("GetByName", mock.Anything, "DerivedCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).
+			Run(func(args mock.Arguments) {
+				now := time.Now()
+				args.Get(1).(*core.Company).CreatedAt = &now
+			}).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"name":"DerivedCo","employees":60,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "medium", response["sizeCategory"])
+		assert.Equal(t, float64(0), response["companyAgeDays"])
+		assert.Contains(t, response, "createdAt")
+	})
+
+	t.Run("minimal response", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		repo.On("GetByName", mock.Anything, "MinimalCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"name":"MinimalCo","description":"long text","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Prefer", "return=minimal")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"))
+
+		var response map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Len(t, response, 1)
+		assert.Equal(t, "/companies/"+response["id"].(string), rec.Header().Get("Location"))
+	})
+
+	t.Run("representation response", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		repo.On("GetByName", mock.Anything, "FullCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"name":"FullCo","description":"long text","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Prefer", "return=representation")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get("Preference-Applied"))
+
+		var response core.Company
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "FullCo", response.Name)
+		require.NotNil(t, response.Description)
+		assert.Equal(t, "long text", *response.Description)
+		assert.Equal(t, "/companies/"+response.ID.String(), rec.Header().Get("Location"))
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		existing := &core.Company{ID: uuid.New(), Name: "TakenCo", Type: core.TypeCorporations}
+		repo.On("GetByName", mock.Anything, "TakenCo").Return(existing, nil)
+
+		body := `{"name":"TakenCo","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Empty(t, rec.Header().Get("Preference-Applied"))
+	})
+
+	t.Run("duplicate name with return-existing preference", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		existing := &core.Company{ID: uuid.New(), Name: "TakenCo", Employees: 3, Type: core.TypeCorporations}
+		repo.On("GetByName", mock.Anything, "TakenCo").Return(existing, nil)
+
+		body := `{"name":"TakenCo","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Prefer", "resolution=return-existing")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "resolution=return-existing", rec.Header().Get("Preference-Applied"))
+		assert.Equal(t, "/companies/"+existing.ID.String(), rec.Header().Get("Location"))
+
+		var response core.Company
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, existing.ID, response.ID)
+		assert.Equal(t, 3, response.Employees)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("return-existing preference without conflict creates", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		repo.On("GetByName", mock.Anything, "FreshCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"name":"FreshCo","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Prefer", "resolution=return-existing")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get("Preference-Applied"))
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		h, _, _ := setupTestHandler()
+
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString("invalid json"))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		h, _, _ := setupTestHandler()
+
+		// Name too long
+		body := `{"name":"ThisNameIsTooLongForOurLimit","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestHandler_Create_Type(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      string
+		want     core.CompanyType
+		wantBody string
+	}{
+		{"canonical", `"Corporations"`, core.TypeCorporations, ""},
+		{"mixed case", `"sole PROPRIETORSHIP"`, core.TypeSoleProprietorship, ""},
+		{"unknown", `"Corporation"`, "", "invalid company type: Corporation"},
+		{"not a string", `7`, "", "type must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, producer := setupTestHandler()
+			repo.On("GetByName", mock.Anything, "TypedCo").Return(nil, nil)
+			repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+			producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+			body := `{"name":"TypedCo","employees":10,"registered":true,"type":` + tt.typ + `}`
+			req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			h.Create(rec, req)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), tt.wantBody)
+				repo.AssertNotCalled(t, "GetByName", mock.Anything, mock.Anything)
+				return
+			}
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			var response core.Company
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, tt.want, response.Type)
+		})
+	}
+}
+
+func TestHandler_Create_ClientID(t *testing.T) {
+	t.Run("supplied ID is used", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByName", mock.Anything, "OwnIDCo").Return(nil, nil)
+		repo.On("GetByID", mock.Anything, id).Return(nil, core.ErrNotFound)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"id":"` + id.String() + `","name":"OwnIDCo","type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/companies/"+id.String(), rec.Header().Get("Location"))
+	})
+
+	t.Run("supplied ID already exists", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByName", mock.Anything, "OwnIDCo").Return(nil, nil)
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{ID: id, Name: "OtherCo"}, nil)
+
+		body := `{"id":"` + id.String() + `","name":"OwnIDCo","type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("supplied ID taken by a concurrent insert", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByName", mock.Anything, "OwnIDCo").Return(nil, nil)
+		repo.On("GetByID", mock.Anything, id).Return(nil, core.ErrNotFound)
+		// The primary-key violation the postgres repository translates
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(core.ErrDuplicateID)
+
+		body := `{"id":"` + id.String() + `","name":"OwnIDCo","type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "company ID already exists")
+	})
+
+	t.Run("malformed ID is rejected", func(t *testing.T) {
+		for _, id := range []string{"not-a-uuid", "00000000-0000-0000-0000-000000000000", ""} {
+			h, repo, _ := setupTestHandler()
+
+			body := `{"id":"` + id + `","name":"OwnIDCo","type":"Corporations"}`
+			req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			h.Create(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "id=%q", id)
+			repo.AssertNotCalled(t, "GetByName", mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func TestHandler_Create_RequiredFields(t *testing.T) {
+	newHandler := func() (*Handler, *MockRepository, *MockEventProducer) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := service.NewCompanyService(repo, producer)
+		return NewHandler(svc, WithRequiredFields([]string{"description"})), repo, producer
+	}
+
+	t.Run("omitted required field is rejected", func(t *testing.T) {
+		h, repo, _ := newHandler()
+
+		body := `{"name":"NoDescCo","employees":10,"registered":true,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "description is required")
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("present required field is accepted", func(t *testing.T) {
+		h, repo, producer := newHandler()
+
+		repo.On("GetByName", mock.Anything, "DescCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"name":"DescCo","description":"","type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("fields are optional by default", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		repo.On("GetByName", mock.Anything, "LeanCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+		body := `{"name":"LeanCo","type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
+
+func TestHandler_Create_TypeDefaults(t *testing.T) {
+	defaults, err := service.ParseTypeDefaults(`{"Sole Proprietorship": {"employees": 1, "registered": false}}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		body          string
+		wantEmployees int
+	}{
+		{"omitted employees defaults", `{"name":"Solo","type":"Sole Proprietorship"}`, 1},
+		{"explicit zero is kept", `{"name":"Solo","employees":0,"type":"Sole Proprietorship"}`, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			producer := new(MockEventProducer)
+			h := NewHandler(service.NewCompanyService(repo, producer, service.WithTypeDefaults(defaults)))
+
+			repo.On("GetByName", mock.Anything, "Solo").Return(nil, nil)
+			repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+			producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			h.Create(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+			var got core.Company
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.wantEmployees, got.Employees)
+		})
+	}
+}
+
+func TestHandler_Create_Dedup(t *testing.T) {
+	body := `{"name":"ClickCo","employees":5,"registered":true,"type":"Corporations"}`
+	post := func(h *Handler, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, user))
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+	setup := func() (*Handler, *MockRepository, *time.Time) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		h := NewHandler(service.NewCompanyService(repo, producer), WithDedupWindow(5*time.Second))
+		now := time.Now()
+		h.dedup.now = func() time.Time { return now }
+
+		// The first create stores the company; later ones see the name taken
+		repo.On("GetByName", mock.Anything, "ClickCo").Return(nil, nil).Once()
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).
+			Run(func(args mock.Arguments) {
+				stored := args.Get(1).(*core.Company)
+				repo.On("GetByID", mock.Anything, stored.ID).Return(stored, nil)
+			}).Return(nil).Once()
+		repo.On("GetByName", mock.Anything, "ClickCo").Return(&core.Company{Name: "ClickCo"}, nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+		return h, repo, &now
+	}
+
+	t.Run("rapid duplicate returns the first company", func(t *testing.T) {
+		h, repo, _ := setup()
+
+		first := post(h, "alice", body)
+		require.Equal(t, http.StatusCreated, first.Code)
+		second := post(h, "alice", body)
+
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Header().Get("Location"), second.Header().Get("Location"))
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+		repo.AssertNumberOfCalls(t, "Create", 1)
+	})
+
+	t.Run("replay is marked", func(t *testing.T) {
+		h, _, _ := setup()
+
+		first := post(h, "alice", body)
+		require.Equal(t, http.StatusCreated, first.Code)
+		second := post(h, "alice", body)
+
+		assert.Empty(t, first.Header().Get("Idempotency-Replayed"))
+		assert.Empty(t, first.Header().Get("Content-Location"))
+
+		require.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, "true", second.Header().Get("Idempotency-Replayed"))
+		assert.Equal(t, first.Header().Get("Location"), second.Header().Get("Content-Location"))
+		var replayed core.Company
+		require.NoError(t, json.Unmarshal(second.Body.Bytes(), &replayed))
+		assert.Equal(t, "ClickCo", replayed.Name)
+	})
+
+	t.Run("another user conflicts", func(t *testing.T) {
+		h, _, _ := setup()
+
+		require.Equal(t, http.StatusCreated, post(h, "alice", body).Code)
+		assert.Equal(t, http.StatusConflict, post(h, "bob", body).Code)
+	})
+
+	t.Run("another client behind the mock auth conflicts", func(t *testing.T) {
+		h, _, _ := setup()
+		postFrom := func(remoteAddr string) int {
+			req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = remoteAddr
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, middleware.MockUserID))
+			rec := httptest.NewRecorder()
+			h.Create(rec, req)
+			return rec.Code
+		}
+
+		require.Equal(t, http.StatusCreated, postFrom("192.0.2.1:1234"))
+		assert.Equal(t, http.StatusConflict, postFrom("192.0.2.2:1234"))
+	})
+
+	t.Run("different payload conflicts", func(t *testing.T) {
+		h, _, _ := setup()
+
+		require.Equal(t, http.StatusCreated, post(h, "alice", body).Code)
+		other := `{"name":"ClickCo","employees":6,"registered":true,"type":"Corporations"}`
+		assert.Equal(t, http.StatusConflict, post(h, "alice", other).Code)
+	})
+
+	t.Run("submit after the window conflicts", func(t *testing.T) {
+		h, _, now := setup()
+
+		require.Equal(t, http.StatusCreated, post(h, "alice", body).Code)
+		*now = now.Add(5 * time.Second)
+		assert.Equal(t, http.StatusConflict, post(h, "alice", body).Code)
+	})
+}
+
+func TestDedupCache_Expiry(t *testing.T) {
+	d := newDedupCache(5 * time.Second)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	first, second := uuid.New(), uuid.New()
+	d.store("a", first)
+	now = now.Add(3 * time.Second)
+	d.store("b", second)
+
+	// An expired entry is dropped on lookup
+	now = now.Add(2 * time.Second)
+	_, ok := d.lookup("a")
+	assert.False(t, ok)
+	assert.Len(t, d.entries, 1)
+
+	// Storing drops expired entries without touching live ones
+	d.store("a", first)
+	now = now.Add(3 * time.Second)
+	d.store("c", uuid.New())
+	assert.Len(t, d.entries, 2)
+	id, ok := d.lookup("a")
+	assert.True(t, ok)
+	assert.Equal(t, first, id)
+	_, ok = d.lookup("b")
+	assert.False(t, ok)
+}
+
+func TestHandler_Create_SimilarNames(t *testing.T) {
+	body := `{"name":"Acme","employees":5,"registered":true,"type":"Corporations"}`
+	setup := func(enabled bool, similar []*core.CompanyName, err error) (*Handler, *MockRepository) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		h := NewHandler(service.NewCompanyService(repo, producer), WithSimilarNameWarnings(enabled))
+
+		repo.On("GetByName", mock.Anything, "Acme").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		repo.On("FindSimilarNames", mock.Anything, "Acme").Return(similar, err)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+		return h, repo
+	}
+	post := func(h *Handler, prefer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+	nearDuplicates := []*core.CompanyName{{ID: uuid.New(), Name: "ACME"}, {ID: uuid.New(), Name: "Acme2"}}
+
+	t.Run("near-duplicates are listed", func(t *testing.T) {
+		h, _ := setup(true, nearDuplicates, nil)
+
+		rec := post(h, "")
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var resp struct {
+			Name     string    `json:"name"`
+			Warnings []Warning `json:"warnings"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "Acme", resp.Name)
+		require.Len(t, resp.Warnings, 1)
+		assert.Equal(t, WarningSimilarNames, resp.Warnings[0].Code)
+		assert.Equal(t, []string{"ACME", "Acme2"}, resp.Warnings[0].Names)
+	})
+
+	t.Run("no near-duplicates", func(t *testing.T) {
+		h, _ := setup(true, []*core.CompanyName{}, nil)
+
+		rec := post(h, "")
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "warnings")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h, repo := setup(false, nearDuplicates, nil)
+
+		rec := post(h, "")
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "warnings")
+		repo.AssertNotCalled(t, "FindSimilarNames", mock.Anything, mock.Anything)
+	})
+
+	t.Run("check failure still creates", func(t *testing.T) {
+		h, _ := setup(true, nil, errors.New("db down"))
+
+		rec := post(h, "")
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "warnings")
+	})
+
+	t.Run("minimal response omits warnings", func(t *testing.T) {
+		h, repo := setup(true, nearDuplicates, nil)
+
+		rec := post(h, "return=minimal")
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "warnings")
+		repo.AssertNotCalled(t, "FindSimilarNames", mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandler_StringBooleans(t *testing.T) {
+	id := uuid.New()
+	setup := func(lenient bool) (*Handler, *MockRepository) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		h := NewHandler(service.NewCompanyService(repo, producer, service.WithLenientBooleans(lenient)))
+
+		repo.On("GetByName", mock.Anything, mock.Anything).Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{
+			ID: id, Name: "Acme", Employees: 5, Registered: false, Type: core.TypeCorporations,
+		}, nil)
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		return h, repo
+	}
+	create := func(h *Handler, registered string) *httptest.ResponseRecorder {
+		body := `{"name":"Acme","employees":5,"registered":` + registered + `,"type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+	patch := func(h *Handler, registered string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(`{"registered":`+registered+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.Patch(rec, req)
+		return rec
+	}
+	requests := map[string]func(*Handler, string) *httptest.ResponseRecorder{"create": create, "patch": patch}
+
+	for name, send := range requests {
+		t.Run(name+" strict rejects strings", func(t *testing.T) {
+			h, _ := setup(false)
+
+			rec := send(h, `"true"`)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "registered must be a boolean")
+		})
+
+		t.Run(name+" lenient accepts strings", func(t *testing.T) {
+			for _, value := range []string{`"true"`, `"1"`} {
+				h, _ := setup(true)
+
+				rec := send(h, value)
+
+				require.Less(t, rec.Code, 300, value)
+				var resp core.Company
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.True(t, resp.Registered, value)
+			}
+		})
+
+		t.Run(name+" lenient rejects other strings", func(t *testing.T) {
+			h, _ := setup(true)
+
+			rec := send(h, `"yes"`)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `got \"yes\"`)
+		})
+
+		t.Run(name+" booleans still work", func(t *testing.T) {
+			h, _ := setup(false)
+
+			rec := send(h, `true`)
+
+			require.Less(t, rec.Code, 300)
+			var resp core.Company
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.True(t, resp.Registered)
+		})
+	}
+}
+
+func TestHandler_Create_WaitForEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		publishErr error
+		wantStatus int
+	}{
+		{"event acked", nil, http.StatusCreated},
+		{"event not acked", errors.New("broker down"), http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, producer := setupTestHandler()
+
+			repo.On("GetByName", mock.Anything, "AckCo").Return(nil, nil)
+			repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+			producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(tt.publishErr)
+
+			body := `{"name":"AckCo","type":"Corporations"}`
+			req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Prefer", "wait-for-event")
+			rec := httptest.NewRecorder()
+
+			h.Create(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, "wait-for-event", rec.Header().Get("Preference-Applied"))
+
+			var response core.Company
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, "AckCo", response.Name)
+			assert.Equal(t, "/companies/"+response.ID.String(), rec.Header().Get("Location"))
+		})
+	}
+
+	t.Run("failures are ignored by default", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		repo.On("GetByName", mock.Anything, "AckCo").Return(nil, nil)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(errors.New("broker down"))
+
+		body := `{"name":"AckCo","type":"Corporations"}`
+		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.Create(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get("Preference-Applied"))
+	})
+}
+
+func TestHandler_DescriptionLimit(t *testing.T) {
+	defer func(prev int) { core.MaxDescriptionLength = prev }(core.MaxDescriptionLength)
+	core.MaxDescriptionLength = 10
+
+	tests := []struct {
+		name       string
+		length     int
+		wantStatus int
+	}{
+		{"one below limit", 9, http.StatusCreated},
+		{"at limit", 10, http.StatusCreated},
+		{"one above limit", 11, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run("create "+tt.name, func(t *testing.T) {
+			h, repo, producer := setupTestHandler()
+
+			repo.On("GetByName", mock.Anything, "DescCo").Return(nil, nil)
+			repo.On("Create", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+			producer.On("Publish", mock.Anything, "CompanyCreated", mock.Anything).Return(nil)
+
+			body := `{"name":"DescCo","description":"` + strings.Repeat("a", tt.length) + `","type":"Corporations"}`
+			req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			h.Create(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnprocessableEntity {
+				assert.JSONEq(t, `{"error":"description must be 10 characters or fewer","code":"DESCRIPTION_TOO_LONG"}`, rec.Body.String())
+				repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+			}
+		})
+	}
+
+	t.Run("patch one above limit", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{ID: id, Name: "DescCo", Type: core.TypeCorporations}, nil)
+		body := `{"description":"` + strings.Repeat("a", 11) + `"}`
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.JSONEq(t, `{"error":"description must be 10 characters or fewer","code":"DESCRIPTION_TOO_LONG"}`, rec.Body.String())
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("bulk patch item one above limit", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{ID: id, Name: "DescCo", Type: core.TypeCorporations}, nil)
+		body := `[{"id":"` + id.String() + `","updates":{"description":"` + strings.Repeat("a", 11) + `"}}]`
+		req := httptest.NewRequest(http.MethodPatch, "/companies", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.PatchMany(rec, req)
+
+		var resp BulkPatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Results, 1)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Results[0].Status)
+		assert.Equal(t, "DESCRIPTION_TOO_LONG", resp.Results[0].Code)
+	})
+}
+
+func TestHandler_Get(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		expected := &core.Company{
+			ID:         id,
+			Name:       "TestCo",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByID", mock.Anything, id).Return(expected, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/"+id.String(), nil)
+		rec := httptest.NewRecorder()
+
+		// Setup chi router context
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Get(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Name, response["name"])
+		assert.Equal(t, id.String(), response["id"])
+		assert.Equal(t, "small", response["sizeCategory"])
+		assert.NotContains(t, response, "createdAt")
+		assert.NotContains(t, response, "companyAgeDays")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByID", mock.Anything, id).Return(nil, core.ErrNotFound)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/"+id.String(), nil)
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Get(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("invalid UUID", func(t *testing.T) {
+		h, _, _ := setupTestHandler()
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/invalid-uuid", nil)
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "invalid-uuid")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Get(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestHandler_Get_ContentNegotiation(t *testing.T) {
+	id := uuid.MustParse("6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91")
+	desc := "Fish & chips <ltd>"
+
+	tests := []struct {
+		name        string
+		accept      string
+		company     *core.Company
+		contentType string
+		body        string
+	}{
+		{
+			name:        "xml",
+			accept:      "application/xml",
+			company:     &core.Company{ID: id, Name: "TestCo", Description: &desc, Employees: 60, Registered: true, Type: core.TypeNonProfit},
+			contentType: "application/xml; charset=utf-8",
+			body: xml.Header + `<company><id>6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91</id><name>TestCo</name>` +
+				`<description>Fish &amp; chips &lt;ltd&gt;</description><employees>60</employees>` +
+				`<registered>true</registered><type>NonProfit</type><sizeCategory>medium</sizeCategory></company>`,
+		},
+		{
+			name:        "xml without description",
+			accept:      "text/html, text/xml;q=0.9",
+			company:     &core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeCorporations},
+			contentType: "application/xml; charset=utf-8",
+			body: xml.Header + `<company><id>6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91</id><name>TestCo</name>` +
+				`<employees>10</employees><registered>false</registered><type>Corporations</type>` +
+				`<sizeCategory>small</sizeCategory></company>`,
+		},
+		{
+			name:        "json",
+			accept:      "application/json, application/xml",
+			company:     &core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeCorporations},
+			contentType: "application/json",
+			body: `{"id":"6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91","name":"TestCo","employees":10,` +
+				`"registered":false,"type":"Corporations","sizeCategory":"small"}`,
+		},
+		{
+			name:        "json by default",
+			company:     &core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeCorporations},
+			contentType: "application/json",
+			body: `{"id":"6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91","name":"TestCo","employees":10,` +
+				`"registered":false,"type":"Corporations","sizeCategory":"small"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := setupTestHandler()
+			repo.On("GetByID", mock.Anything, id).Return(tt.company, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/companies/"+id.String(), nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", id.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			h.Get(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+			if tt.contentType == "application/json" {
+				assert.JSONEq(t, tt.body, rec.Body.String())
+			} else {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Get_ExpandType(t *testing.T) {
+	id := uuid.MustParse("6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91")
+	labels := map[core.CompanyType]string{core.TypeNonProfit: "Non-profit organisation"}
+
+	get := func(company *core.Company, query, accept string) *httptest.ResponseRecorder {
+		repo := new(MockRepository)
+		repo.On("GetByID", mock.Anything, id).Return(company, nil)
+		h := NewHandler(service.NewCompanyService(repo, new(MockEventProducer)), WithTypeLabels(labels))
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/"+id.String()+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Get(rec, req)
+		return rec
+	}
+
+	t.Run("bare string by default", func(t *testing.T) {
+		for _, query := range []string{"", "?expandType=false"} {
+			rec := get(&core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeNonProfit}, query, "")
+
+			assert.Equal(t, http.StatusOK, rec.Code, query)
+			assert.JSONEq(t, `{"id":"6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91","name":"TestCo","employees":10,`+
+				`"registered":false,"type":"NonProfit","sizeCategory":"small"}`, rec.Body.String(), query)
+		}
+	})
+
+	t.Run("expanded with a configured label", func(t *testing.T) {
+		rec := get(&core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeNonProfit}, "?expandType=true", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":"6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91","name":"TestCo","employees":10,`+
+			`"registered":false,"type":{"code":"NonProfit","label":"Non-profit organisation"},"sizeCategory":"small"}`,
+			rec.Body.String())
+	})
+
+	t.Run("unlabelled type is labelled with its code", func(t *testing.T) {
+		rec := get(&core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeCorporations}, "?expandType=true", "")
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, map[string]interface{}{"code": "Corporations", "label": "Corporations"}, response["type"])
+	})
+
+	t.Run("expanded xml", func(t *testing.T) {
+		rec := get(&core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeNonProfit}, "?expandType=true", "application/xml")
+
+		assert.Equal(t, xml.Header+`<company><id>6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91</id><name>TestCo</name>`+
+			`<employees>10</employees><registered>false</registered><sizeCategory>small</sizeCategory>`+
+			`<type><code>NonProfit</code><label>Non-profit organisation</label></type></company>`, rec.Body.String())
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		rec := get(&core.Company{ID: id}, "?expandType=maybe", "")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid expandType parameter","code":"INVALID_QUERY_PARAM","param":"expandType"}`, rec.Body.String())
+	})
+
+	t.Run("patch still accepts the bare string", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{ID: id, Name: "TestCo", Employees: 10, Type: core.TypeCorporations}, nil)
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyUpdated", mock.Anything).Return(nil)
+		h := NewHandler(service.NewCompanyService(repo, producer), WithTypeLabels(labels))
+
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(`{"type":"NonProfit"}`))
+		rec := httptest.NewRecorder()
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response core.Company
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, core.TypeNonProfit, response.Type)
+	})
+}
+
+func TestParseTypeLabels(t *testing.T) {
+	labels, err := ParseTypeLabels(`{"NonProfit": "Non-profit organisation", "Sole Proprietorship": "Sole trader"}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[core.CompanyType]string{
+		core.TypeNonProfit:          "Non-profit organisation",
+		core.TypeSoleProprietorship: "Sole trader",
+	}, labels)
+
+	for _, raw := range []string{`{"Charity": "Charity"}`, `{"NonProfit": ""}`, `{"NonProfit": 1}`, `not json`} {
+		_, err := ParseTypeLabels(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		echoed string
+	}{
+		{"word", "invalid-uuid", "invalid-uuid"},
+		{"empty", "", ""},
+		{"too short", "6f1c2a4e-3b7d", "6f1c2a4e-3b7d"},
+		{"bad hex", "6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6aZZ", "6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6aZZ"},
+		{"long value is truncated", strings.Repeat("x", 100), strings.Repeat("x", 40) + "..."},
+		{"control characters are replaced", "abc\r\n\x00def", "abc???def"},
+		{"invalid UTF-8 is replaced", "abc\xffdef", "abc?def"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			_, ok := parseID(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.raw)
+
+			assert.False(t, ok)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "invalid UUID format: expected a canonical UUID, got '"+tt.echoed+"'", resp.Error)
+			assert.Equal(t, "INVALID_ID", resp.Code)
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		id := uuid.New()
+
+		got, ok := parseID(rec, httptest.NewRequest(http.MethodGet, "/", nil), id.String())
+
+		assert.True(t, ok)
+		assert.Equal(t, id, got)
+		assert.Empty(t, rec.Body.String())
+	})
+}
+
+func TestSizeCategory(t *testing.T) {
+	tests := []struct {
+		employees int
+		want      string
+	}{
+		{0, SizeSmall},
+		{49, SizeSmall},
+		{50, SizeMedium},
+		{249, SizeMedium},
+		{250, SizeLarge},
+		{10000, SizeLarge},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sizeCategory(tt.employees), "employees=%d", tt.employees)
+	}
+}
+
+func TestCompanyAgeDays(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		createdAt time.Time
+		want      int
+	}{
+		{now, 0},
+		{now.Add(-23 * time.Hour), 0},
+		{now.Add(-24 * time.Hour), 1},
+		{now.Add(-49 * time.Hour), 2},
+		{now.AddDate(-1, 0, 0), 366},
+		{now.Add(time.Hour), 0}, // clock skew between the database and the service
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, companyAgeDays(tt.createdAt, now), "createdAt=%s", tt.createdAt)
+	}
+}
+
+func TestHandler_Aggregate(t *testing.T) {
+	t.Run("all companies", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		repo.On("EmployeeAggregates", mock.Anything, core.CompanyType("")).
+			Return(&core.EmployeeAggregates{Sum: 45, Avg: 15, Max: 30, Min: 5}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/aggregate?metric=employees", nil)
+		rec := httptest.NewRecorder()
+
+		h.Aggregate(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"sum":45,"avg":15,"max":30,"min":5}`, rec.Body.String())
+	})
+
+	t.Run("filtered by type", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		repo.On("EmployeeAggregates", mock.Anything, core.TypeNonProfit).
+			Return(&core.EmployeeAggregates{Sum: 5, Avg: 5, Max: 5, Min: 5}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/aggregate?metric=employees&type=NonProfit", nil)
+		rec := httptest.NewRecorder()
+
+		h.Aggregate(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		tests := []struct {
+			query string
+			param string
+		}{
+			{"", "metric"},
+			{"metric=revenue", "metric"},
+			{"metric=employees&type=Bogus", "type"},
+		}
+
+		for _, tt := range tests {
+			h, repo, _ := setupTestHandler()
+
+			req := httptest.NewRequest(http.MethodGet, "/companies/aggregate?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.Aggregate(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tt.query)
+			assert.JSONEq(t, `{"error":"invalid `+tt.param+` parameter","code":"INVALID_QUERY_PARAM","param":"`+tt.param+`"}`, rec.Body.String(), tt.query)
+			repo.AssertNotCalled(t, "EmployeeAggregates", mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func TestHandler_Distribution(t *testing.T) {
+	t.Run("returns buckets", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		ten, fifty := 10, 50
+		repo.On("EmployeeDistribution", mock.Anything, []int{0, 10, 50}).
+			Return([]core.EmployeeBucket{
+				{Min: 0, Max: &ten, Count: 2},
+				{Min: 10, Max: &fifty, Count: 0},
+				{Min: 50, Count: 1},
+			}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/distribution?field=employees&buckets=0,10,50", nil)
+		rec := httptest.NewRecorder()
+
+		h.Distribution(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"field":"employees","buckets":[
+			{"min":0,"max":10,"count":2},
+			{"min":10,"max":50,"count":0},
+			{"min":50,"count":1}]}`, rec.Body.String())
+	})
+
+	t.Run("rejects unordered bounds", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/distribution?field=employees&buckets=0,50,10", nil)
+		rec := httptest.NewRecorder()
+
+		h.Distribution(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "ascending")
+		repo.AssertNotCalled(t, "EmployeeDistribution", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		tests := []struct {
+			query string
+			param string
+		}{
+			{"buckets=0,10", "field"},
+			{"field=revenue&buckets=0,10", "field"},
+			{"field=employees", "buckets"},
+			{"field=employees&buckets=0,ten", "buckets"},
+			{"field=employees&buckets=0,,10", "buckets"},
+		}
+
+		for _, tt := range tests {
+			h, repo, _ := setupTestHandler()
+
+			req := httptest.NewRequest(http.MethodGet, "/companies/distribution?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.Distribution(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tt.query)
+			assert.JSONEq(t, `{"error":"invalid `+tt.param+` parameter","code":"INVALID_QUERY_PARAM","param":"`+tt.param+`"}`, rec.Body.String(), tt.query)
+			repo.AssertNotCalled(t, "EmployeeDistribution", mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func TestHandler_Count(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		companyType core.CompanyType
+		count       int
+	}{
+		{"all companies", "", "", 42},
+		{"filtered by type", "?type=NonProfit", core.TypeNonProfit, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo, _ := setupTestHandler()
+			repo.On("Count", mock.Anything, tt.companyType).Return(tt.count, nil)
+
+			req := httptest.NewRequest(http.MethodHead, "/companies"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.Count(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, strconv.Itoa(tt.count), rec.Header().Get("X-Total-Count"))
+			assert.Empty(t, rec.Body.String())
+		})
+	}
+
+	t.Run("invalid type", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		req := httptest.NewRequest(http.MethodHead, "/companies?type=Bogus", nil)
+		rec := httptest.NewRecorder()
+
+		h.Count(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Total-Count"))
+		repo.AssertNotCalled(t, "Count", mock.Anything, mock.Anything)
+	})
+
+	t.Run("approximate count of a large table", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+		repo.On("ApproxCount", mock.Anything).Return(2500000, nil)
+
+		req := httptest.NewRequest(http.MethodHead, "/companies?exactCount=false", nil)
+		rec := httptest.NewRecorder()
+
+		h.Count(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2500000", rec.Header().Get("X-Total-Count"))
+		assert.Equal(t, "true", rec.Header().Get("X-Total-Count-Estimated"))
+		repo.AssertNotCalled(t, "Count", mock.Anything, mock.Anything)
+	})
+
+	t.Run("approximate count of a small table is exact", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+		repo.On("ApproxCount", mock.Anything).Return(40, nil)
+		repo.On("Count", mock.Anything, core.CompanyType("")).Return(42, nil)
+
+		req := httptest.NewRequest(http.MethodHead, "/companies?exactCount=false", nil)
+		rec := httptest.NewRecorder()
+
+		h.Count(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "42", rec.Header().Get("X-Total-Count"))
+		assert.Empty(t, rec.Header().Get("X-Total-Count-Estimated"))
+	})
+
+	t.Run("invalid exactCount", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		req := httptest.NewRequest(http.MethodHead, "/companies?exactCount=maybe", nil)
+		rec := httptest.NewRecorder()
+
+		h.Count(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"param":"exactCount"`)
+		repo.AssertNotCalled(t, "ApproxCount", mock.Anything)
+	})
+}
+
+func TestHandler_Search(t *testing.T) {
+	t.Run("returns a page of matches", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("SearchByName", mock.Anything, "50%_off", 5, 10).
+			Return([]*core.Company{{ID: id, Name: "50%_off Ltd", Type: core.TypeCorporations}}, nil)
+
+		// Wildcards reach the repository verbatim; it escapes them
+		req := httptest.NewRequest(http.MethodGet, "/companies/search?q=50%25_off&limit=5&offset=10", nil)
+		rec := httptest.NewRecorder()
+
+		h.Search(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp SearchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Companies, 1)
+		assert.Equal(t, id, resp.Companies[0].ID)
+		assert.Equal(t, SizeSmall, resp.Companies[0].SizeCategory)
+		assert.Equal(t, 5, resp.Limit)
+		assert.Equal(t, 10, resp.Offset)
+	})
+
+	t.Run("no matches is an empty list", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		repo.On("SearchByName", mock.Anything, "zz", 20, 0).Return([]*core.Company{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/search?q=zz", nil)
 		rec := httptest.NewRecorder()
 
-		h.Create(rec, req)
-
-		assert.Equal(t, http.StatusCreated, rec.Code)
+		h.Search(rec, req)
 
-		var response core.Company
-		err := json.Unmarshal(rec.Body.Bytes(), &response)
-		require.NoError(t, err)
-		assert.Equal(t, "TestCo", response.Name)
-		assert.NotEqual(t, uuid.Nil, response.ID)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"companies":[],"limit":20,"offset":0}`, rec.Body.String())
 	})
 
-	t.Run("invalid JSON", func(t *testing.T) {
-		h, _, _ := setupTestHandler()
+	t.Run("invalid parameters", func(t *testing.T) {
+		tests := []struct {
+			query string
+			param string
+		}{
+			{"q=acme&limit=abc", "limit"},
+			{"q=acme&limit=0", "limit"},
+			{"q=acme&limit=101", "limit"},
+			{"q=acme&offset=abc", "offset"},
+			{"q=acme&offset=-1", "offset"},
+		}
 
-		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString("invalid json"))
-		req.Header.Set("Content-Type", "application/json")
-		rec := httptest.NewRecorder()
+		for _, tt := range tests {
+			h, repo, _ := setupTestHandler()
 
-		h.Create(rec, req)
+			req := httptest.NewRequest(http.MethodGet, "/companies/search?"+tt.query, nil)
+			rec := httptest.NewRecorder()
 
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+			h.Search(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tt.query)
+			assert.JSONEq(t, `{"error":"invalid `+tt.param+` parameter","code":"INVALID_QUERY_PARAM","param":"`+tt.param+`"}`, rec.Body.String(), tt.query)
+			repo.AssertNotCalled(t, "SearchByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		}
 	})
 
-	t.Run("validation error", func(t *testing.T) {
-		h, _, _ := setupTestHandler()
+	t.Run("query too short", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
 
-		// Name too long
-		body := `{"name":"ThisNameIsTooLongForOurLimit","employees":10,"registered":true,"type":"Corporations"}`
-		req := httptest.NewRequest(http.MethodPost, "/companies", bytes.NewBufferString(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, "/companies/search?q=a", nil)
 		rec := httptest.NewRecorder()
 
-		h.Create(rec, req)
+		h.Search(rec, req)
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		repo.AssertNotCalled(t, "SearchByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	})
 }
 
-func TestHandler_Get(t *testing.T) {
-	t.Run("found", func(t *testing.T) {
+func TestHandler_Autocomplete(t *testing.T) {
+	t.Run("returns id and name", func(t *testing.T) {
 		h, repo, _ := setupTestHandler()
 
 		id := uuid.New()
-		expected := &core.Company{
-			ID:         id,
-			Name:       "TestCo",
-			Employees:  10,
-			Registered: true,
-			Type:       core.TypeCorporations,
-		}
-
-		repo.On("GetByID", mock.Anything, id).Return(expected, nil)
+		repo.On("NamePrefixSearch", mock.Anything, "ac", 5).
+			Return([]*core.CompanyName{{ID: id, Name: "Acme"}}, nil)
 
-		req := httptest.NewRequest(http.MethodGet, "/companies/"+id.String(), nil)
+		req := httptest.NewRequest(http.MethodGet, "/companies/autocomplete?prefix=ac&limit=5", nil)
 		rec := httptest.NewRecorder()
 
-		// Setup chi router context
-		rctx := chi.NewRouteContext()
-		rctx.URLParams.Add("id", id.String())
-		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		h.Autocomplete(rec, req)
 
-		h.Get(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"companies":[{"id":"`+id.String()+`","name":"Acme"}]}`, rec.Body.String())
+	})
+
+	t.Run("no matches is an empty list", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		repo.On("NamePrefixSearch", mock.Anything, "zz", 10).Return([]*core.CompanyName{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/companies/autocomplete?prefix=zz", nil)
+		rec := httptest.NewRecorder()
+
+		h.Autocomplete(rec, req)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"companies":[]}`, rec.Body.String())
+	})
 
-		var response core.Company
-		err := json.Unmarshal(rec.Body.Bytes(), &response)
-		require.NoError(t, err)
-		assert.Equal(t, expected.Name, response.Name)
+	t.Run("invalid parameters", func(t *testing.T) {
+		tests := []struct {
+			query string
+			param string
+		}{
+			{"prefix=ac&limit=abc", "limit"},
+			{"prefix=ac&limit=0", "limit"},
+			{"prefix=ac&limit=26", "limit"},
+		}
+
+		for _, tt := range tests {
+			h, repo, _ := setupTestHandler()
+
+			req := httptest.NewRequest(http.MethodGet, "/companies/autocomplete?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.Autocomplete(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tt.query)
+			assert.JSONEq(t, `{"error":"invalid `+tt.param+` parameter","code":"INVALID_QUERY_PARAM","param":"`+tt.param+`"}`, rec.Body.String(), tt.query)
+			repo.AssertNotCalled(t, "NamePrefixSearch", mock.Anything, mock.Anything, mock.Anything)
+		}
 	})
 
-	t.Run("not found", func(t *testing.T) {
+	t.Run("prefix too short", func(t *testing.T) {
 		h, repo, _ := setupTestHandler()
 
-		id := uuid.New()
-		repo.On("GetByID", mock.Anything, id).Return(nil, core.ErrNotFound)
+		req := httptest.NewRequest(http.MethodGet, "/companies/autocomplete?prefix=a", nil)
+		rec := httptest.NewRecorder()
 
-		req := httptest.NewRequest(http.MethodGet, "/companies/"+id.String(), nil)
+		h.Autocomplete(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		repo.AssertNotCalled(t, "NamePrefixSearch", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandler_PatchMany(t *testing.T) {
+	idA, idB := uuid.New(), uuid.New()
+	setup := func() (*Handler, *MockRepository, *MockEventProducer) {
+		h, repo, producer := setupTestHandler()
+		repo.On("GetByID", mock.Anything, idA).Return(&core.Company{ID: idA, Name: "Alpha", Employees: 10, Registered: true, Type: core.TypeCorporations}, nil)
+		repo.On("GetByID", mock.Anything, idB).Return(&core.Company{ID: idB, Name: "Beta", Employees: 20, Registered: true, Type: core.TypeNonProfit}, nil)
+		return h, repo, producer
+	}
+	patch := func(h *Handler, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/companies"+query, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
+		h.PatchMany(rec, req)
+		return rec
+	}
 
-		rctx := chi.NewRouteContext()
-		rctx.URLParams.Add("id", id.String())
-		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	t.Run("all items applied", func(t *testing.T) {
+		h, repo, producer := setup()
+		repo.On("UpdateMany", mock.Anything, mock.Anything).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyUpdated", mock.Anything).Return(nil)
 
-		h.Get(rec, req)
+		rec := patch(h, "", `[{"id":"`+idA.String()+`","updates":{"employees":11}},{"id":"`+idB.String()+`","updates":{"employees":20}}]`)
 
-		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp BulkPatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.True(t, resp.Atomic)
+		require.Len(t, resp.Results, 2)
+		assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+		assert.Equal(t, 11, resp.Results[0].Company.Employees)
+		assert.Equal(t, SizeSmall, resp.Results[0].Company.SizeCategory)
+		assert.False(t, resp.Results[0].Unchanged)
+		assert.True(t, resp.Results[1].Unchanged)
 	})
 
-	t.Run("invalid UUID", func(t *testing.T) {
-		h, _, _ := setupTestHandler()
+	t.Run("atomic failure reports each item", func(t *testing.T) {
+		h, repo, _ := setup()
+		missing := uuid.New()
+		repo.On("GetByID", mock.Anything, missing).Return(nil, core.ErrNotFound)
+
+		rec := patch(h, "", `[{"id":"`+idA.String()+`","updates":{"employees":11}},{"id":"`+idB.String()+`","updates":{"employees":2.5}},{"id":"`+missing.String()+`","updates":{"employees":1}}]`)
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		var resp BulkPatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Results, 3)
+		assert.Equal(t, BulkPatchResult{ID: idA.String(), Status: http.StatusFailedDependency, Error: core.ErrBatchAborted.Error(), Code: "BATCH_ABORTED"}, resp.Results[0])
+		assert.Equal(t, http.StatusBadRequest, resp.Results[1].Status)
+		assert.Equal(t, "employees must be a whole number", resp.Results[1].Error)
+		assert.Equal(t, http.StatusNotFound, resp.Results[2].Status)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+	})
 
-		req := httptest.NewRequest(http.MethodGet, "/companies/invalid-uuid", nil)
-		rec := httptest.NewRecorder()
+	t.Run("non-atomic applies valid items", func(t *testing.T) {
+		h, repo, producer := setup()
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyUpdated", mock.Anything).Return(nil)
 
-		rctx := chi.NewRouteContext()
-		rctx.URLParams.Add("id", "invalid-uuid")
-		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := patch(h, "?atomic=false", `[{"id":"`+idA.String()+`","updates":{"employees":11}},{"id":"`+idB.String()+`","updates":{"type":"Bogus"}}]`)
 
-		h.Get(rec, req)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		var resp BulkPatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.False(t, resp.Atomic)
+		assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+		assert.Equal(t, http.StatusBadRequest, resp.Results[1].Status)
+		repo.AssertNumberOfCalls(t, "Update", 1)
+	})
 
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	t.Run("malformed requests", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query string
+			body  string
+		}{
+			{"invalid atomic", "?atomic=maybe", `[]`},
+			{"not an array", "", `{"id":"` + idA.String() + `"}`},
+			{"invalid id", "", `[{"id":"nope","updates":{"employees":1}}]`},
+			{"empty updates", "", `[{"id":"` + idA.String() + `","updates":{}}]`},
+			{"empty batch", "", `[]`},
+		}
+
+		for _, tt := range tests {
+			h, repo, _ := setup()
+
+			rec := patch(h, tt.query, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tt.name)
+			repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+		}
 	})
 }
 
@@ -241,12 +1779,14 @@ func TestHandler_Patch(t *testing.T) {
 		h, repo, producer := setupTestHandler()
 
 		id := uuid.New()
+		createdAt := time.Now().Add(-49 * time.Hour)
 		existing := &core.Company{
 			ID:         id,
 			Name:       "OldName",
 			Employees:  10,
 			Registered: true,
 			Type:       core.TypeCorporations,
+			CreatedAt:  &createdAt,
 		}
 
 		repo.On("GetByID", mock.Anything, id).Return(existing, nil)
@@ -267,11 +1807,118 @@ func TestHandler_Patch(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var response core.Company
+		var response CompanyResponse
 		err := json.Unmarshal(rec.Body.Bytes(), &response)
 		require.NoError(t, err)
 		assert.Equal(t, "NewName", response.Name)
 		assert.Equal(t, 20, response.Employees)
+		assert.Equal(t, SizeSmall, response.SizeCategory)
+		require.NotNil(t, response.CompanyAgeDays)
+		assert.Equal(t, 2, *response.CompanyAgeDays)
+		assert.Empty(t, rec.Header().Get("X-Unchanged"))
+	})
+
+	t.Run("no-op patch", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		id := uuid.New()
+		existing := &core.Company{
+			ID:         id,
+			Name:       "SameName",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByID", mock.Anything, id).Return(existing, nil)
+
+		body := `{"name":"SameName","employees":10}`
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("X-Unchanged"))
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("minimal response", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		id := uuid.New()
+		existing := &core.Company{
+			ID:         id,
+			Name:       "MinimalCo",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByID", mock.Anything, id).Return(existing, nil)
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyUpdated", mock.Anything).Return(nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(`{"employees":20}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Prefer", "return=minimal")
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":"`+id.String()+`"}`, rec.Body.String())
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{ID: id, Name: "TypedCo", Type: core.TypeCorporations}, nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(`{"type":"Bogus"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid company type: Bogus")
+	})
+
+	t.Run("fractional employees", func(t *testing.T) {
+		h, repo, _ := setupTestHandler()
+
+		id := uuid.New()
+		repo.On("GetByID", mock.Anything, id).Return(&core.Company{ID: id, Name: "CountCo", Type: core.TypeCorporations}, nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String(), bytes.NewBufferString(`{"employees":25.5}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "employees must be a whole number")
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
 	})
 
 	t.Run("empty update body", func(t *testing.T) {
@@ -291,4 +1938,176 @@ func TestHandler_Patch(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
+
+	t.Run("query parameters without body", func(t *testing.T) {
+		h, repo, producer := setupTestHandler()
+
+		id := uuid.New()
+		existing := &core.Company{
+			ID:         id,
+			Name:       "QueryCo",
+			Employees:  10,
+			Registered: false,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByID", mock.Anything, id).Return(existing, nil)
+		repo.On("Update", mock.Anything, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", mock.Anything, "CompanyUpdated", mock.Anything).Return(nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String()+"?registered=true&employees=42", nil)
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response core.Company
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.True(t, response.Registered)
+		assert.Equal(t, 42, response.Employees)
+	})
+
+	t.Run("invalid query parameters", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query string
+			want  string
+		}{
+			{"unknown parameter", "?foo=bar", "unknown query parameter: foo"},
+			{"non-boolean registered", "?registered=maybe", "registered must be a boolean"},
+			{"non-numeric employees", "?employees=many", "employees must be a number"},
+			{"repeated parameter", "?name=A&name=B", "exactly once"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				h, _, _ := setupTestHandler()
+
+				id := uuid.New()
+				req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String()+tt.query, nil)
+				rec := httptest.NewRecorder()
+
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("id", id.String())
+				req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+				h.Patch(rec, req)
+
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), tt.want)
+			})
+		}
+	})
+
+	t.Run("query parameters with body are rejected", func(t *testing.T) {
+		h, _, _ := setupTestHandler()
+
+		id := uuid.New()
+		req := httptest.NewRequest(http.MethodPatch, "/companies/"+id.String()+"?registered=true", bytes.NewBufferString(`{"employees":5}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		h.Patch(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestHandleServiceError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		retryAfter string
+	}{
+		{"not found", core.ErrNotFound, http.StatusNotFound, ""},
+		{"duplicate name", core.NewDuplicateNameError("TakenCo"), http.StatusConflict, ""},
+		{"retryable duplicate name", core.WithRetryAfter(core.NewDuplicateNameError("TakenCo"), 90*time.Second), http.StatusConflict, "90"},
+		{"unavailable", core.ErrUnavailable, http.StatusServiceUnavailable, "30"},
+		{"unavailable with hint", core.WithRetryAfter(core.ErrUnavailable, 1500*time.Millisecond), http.StatusServiceUnavailable, "2"},
+		{"read-only database", core.ErrReadOnly, http.StatusServiceUnavailable, "5"},
+		{"quota exceeded", core.ErrQuotaExceeded, http.StatusForbidden, ""},
+		{"untyped error is not a validation error", errors.New("name is required"), http.StatusInternalServerError, ""},
+		{"typed validation", core.NewValidationError("type", "unsupported type"), http.StatusBadRequest, ""},
+		{"internal", errors.New("connection reset"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			handleServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.retryAfter, rec.Header().Get("Retry-After"))
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestHandleServiceError_Code(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	handleServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), core.ErrQuotaExceeded)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.JSONEq(t, `{"error":"company quota exceeded","code":"QUOTA_EXCEEDED"}`, rec.Body.String())
+}
+
+func TestRespondError_ContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "browser gets html",
+			accept:      "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			contentType: "text/html; charset=utf-8",
+			body: "<!DOCTYPE html>\n<html><head><title>404 Not Found</title></head>" +
+				"<body><h1>404 Not Found</h1><p>no &lt;such&gt; company</p></body></html>\n",
+		},
+		{
+			name:        "json client",
+			accept:      "application/json, text/html",
+			contentType: "application/json",
+			body:        `{"error":"no <such> company"}`,
+		},
+		{
+			name:        "json by default",
+			contentType: "application/json",
+			body:        `{"error":"no <such> company"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/companies/x", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			respondError(rec, req, "no <such> company", http.StatusNotFound)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			if tt.contentType == "application/json" {
+				assert.JSONEq(t, tt.body, rec.Body.String())
+			} else {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
 }
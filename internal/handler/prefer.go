@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Preference values understood by the handlers (RFC 7240)
+const (
+	preferReturn        = "return"
+	preferReturnMinimal = "minimal"
+
+	preferResolution               = "resolution"
+	preferResolutionReturnExisting = "return-existing"
+
+	preferWaitForEvent = "wait-for-event"
+)
+
+// preferences parses the Prefer request headers into a map of
+// preference name to value. Names are case-insensitive; parameters after
+// ';' are ignored and the first occurrence of a preference wins.
+func preferences(r *http.Request) map[string]string {
+	prefs := make(map[string]string)
+	for _, header := range r.Header.Values("Prefer") {
+		for _, token := range strings.Split(header, ",") {
+			token, _, _ = strings.Cut(token, ";")
+			name, value, _ := strings.Cut(strings.TrimSpace(token), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, seen := prefs[name]; !seen {
+				prefs[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return prefs
+}
+
+// prefersMinimal reports whether the client asked for Prefer: return=minimal
+func prefersMinimal(r *http.Request) bool {
+	return preferences(r)[preferReturn] == preferReturnMinimal
+}
+
+// prefersReturnExisting reports whether the client asked for
+// Prefer: resolution=return-existing
+func prefersReturnExisting(r *http.Request) bool {
+	return preferences(r)[preferResolution] == preferResolutionReturnExisting
+}
+
+// prefersWaitForEvent reports whether the client sent Prefer: wait-for-event
+func prefersWaitForEvent(r *http.Request) bool {
+	_, ok := preferences(r)[preferWaitForEvent]
+	return ok
+}
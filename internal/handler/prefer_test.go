@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    map[string]string
+	}{
+		{
+			name: "no header",
+			want: map[string]string{},
+		},
+		{
+			name:    "single preference",
+			headers: []string{"return=minimal"},
+			want:    map[string]string{"return": "minimal"},
+		},
+		{
+			name:    "multiple preferences and parameters",
+			headers: []string{`Return="minimal"; foo=bar, respond-async`},
+			want:    map[string]string{"return": "minimal", "respond-async": ""},
+		},
+		{
+			name:    "first occurrence wins across headers",
+			headers: []string{"return=representation", "return=minimal"},
+			want:    map[string]string{"return": "representation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, h := range tt.headers {
+				req.Header.Add("Prefer", h)
+			}
+
+			assert.Equal(t, tt.want, preferences(req))
+		})
+	}
+}
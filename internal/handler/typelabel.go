@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"xm-company-service/internal/core"
+)
+
+// TypeObject is the expanded form of a company type returned for
+// ?expandType=true
+type TypeObject struct {
+	Code  core.CompanyType `json:"code" xml:"code"`
+	Label string           `json:"label" xml:"label"`
+}
+
+// ExpandedCompanyResponse is CompanyResponse with the type as a TypeObject
+// instead of a bare string
+type ExpandedCompanyResponse struct {
+	CompanyResponse
+	Type TypeObject `json:"type" xml:"type"`
+}
+
+// WithTypeLabels sets the display labels returned for expanded company
+// types. Types without a label are labelled with their code.
+func WithTypeLabels(labels map[core.CompanyType]string) Option {
+	return func(h *Handler) {
+		h.typeLabels = labels
+	}
+}
+
+// ParseTypeLabels parses the TYPE_LABELS JSON object, e.g.
+// {"NonProfit": "Non-profit organisation"}
+func ParseTypeLabels(raw string) (map[core.CompanyType]string, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+
+	var labels map[core.CompanyType]string
+	if err := dec.Decode(&labels); err != nil {
+		return nil, err
+	}
+	for companyType, label := range labels {
+		if !companyType.IsValid() {
+			return nil, fmt.Errorf("invalid company type: %s", companyType)
+		}
+		if label == "" {
+			return nil, fmt.Errorf("empty label for company type %s", companyType)
+		}
+	}
+	return labels, nil
+}
+
+// expandType returns ct with its display label
+func (h *Handler) expandType(ct core.CompanyType) TypeObject {
+	label, ok := h.typeLabels[ct]
+	if !ok {
+		label = string(ct)
+	}
+	return TypeObject{Code: ct, Label: label}
+}
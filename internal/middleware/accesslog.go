@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLog logs every request like chi's Logger, except that 2xx
+// responses are logged only with probability rate, so errors stay visible
+// while routine traffic is thinned out. A rate of 1 or more logs everything.
+func AccessLog(rate float64) func(http.Handler) http.Handler {
+	if rate >= 1 {
+		return chimiddleware.Logger
+	}
+	formatter := &chimiddleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags), NoColor: true}
+	return chimiddleware.RequestLogger(newSampledFormatter(formatter, rate, rand.Float64))
+}
+
+// sampledFormatter drops a share of the log entries of 2xx responses
+type sampledFormatter struct {
+	next chimiddleware.LogFormatter
+	rate float64
+	// sample returns a number in [0, 1); an entry is kept when it is below rate
+	sample func() float64
+}
+
+func newSampledFormatter(next chimiddleware.LogFormatter, rate float64, sample func() float64) *sampledFormatter {
+	return &sampledFormatter{next: next, rate: rate, sample: sample}
+}
+
+func (f *sampledFormatter) NewLogEntry(r *http.Request) chimiddleware.LogEntry {
+	return &sampledEntry{LogEntry: f.next.NewLogEntry(r), formatter: f}
+}
+
+type sampledEntry struct {
+	chimiddleware.LogEntry
+	formatter *sampledFormatter
+}
+
+func (e *sampledEntry) Write(status, bytes int, header http.Header, elapsed time.Duration, extra interface{}) {
+	if status >= 200 && status < 300 && e.formatter.sample() >= e.formatter.rate {
+		return
+	}
+	e.LogEntry.Write(status, bytes, header, elapsed, extra)
+}
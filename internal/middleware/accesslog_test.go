@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog_Sampling(t *testing.T) {
+	const requests = 10000
+
+	serve := func(rate float64, status int) int {
+		var buf bytes.Buffer
+		formatter := &chimiddleware.DefaultLogFormatter{Logger: log.New(&buf, "", 0), NoColor: true}
+		sampler := rand.New(rand.NewPCG(1, 2))
+		mw := chimiddleware.RequestLogger(newSampledFormatter(formatter, rate, sampler.Float64))
+		h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		for i := 0; i < requests; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/companies/search?q=ac", nil))
+		}
+		return strings.Count(buf.String(), "\n")
+	}
+
+	t.Run("successes are sampled at the rate", func(t *testing.T) {
+		logged := serve(0.1, http.StatusOK)
+		assert.InDelta(t, requests*0.1, logged, requests*0.01)
+	})
+
+	t.Run("zero rate drops all successes", func(t *testing.T) {
+		assert.Zero(t, serve(0, http.StatusOK))
+	})
+
+	t.Run("errors are always logged", func(t *testing.T) {
+		assert.Equal(t, requests, serve(0, http.StatusNotFound))
+		assert.Equal(t, requests, serve(0, http.StatusInternalServerError))
+	})
+}
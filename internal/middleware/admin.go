@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// AdminKeyHeader carries the admin API key
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminAuth restricts routes to callers presenting the admin API key.
+// It stands in for an admin role until tokens carry role claims. With an
+// empty apiKey every request is rejected, so admin routes stay closed
+// unless a key is configured.
+func AdminAuth(apiKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(AdminKeyHeader)
+			if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+				http.Error(w, `{"error": "admin access required"}`, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
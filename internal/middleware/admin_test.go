@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		configured string
+		presented  string
+		want       int
+	}{
+		{"matching key", "s3cret", "s3cret", http.StatusNoContent},
+		{"wrong key", "s3cret", "guess", http.StatusForbidden},
+		{"missing key", "s3cret", "", http.StatusForbidden},
+		{"no key configured", "", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/migrate", nil)
+			if tt.presented != "" {
+				req.Header.Set(AdminKeyHeader, tt.presented)
+			}
+			rec := httptest.NewRecorder()
+
+			AdminAuth(tt.configured)(ok).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -14,6 +15,9 @@ const (
 	UserIDKey ContextKey = "userID"
 )
 
+// MockUserID is the user ID the mock JWTAuth gives every request
+const MockUserID = "mock-user-id"
+
 // JWTAuth is a middleware that validates JWT tokens
 // This is a mock implementation for the exercise
 // In production, you would:
@@ -56,7 +60,7 @@ func JWTAuth(next http.Handler) http.Handler {
 		*/
 
 		// Add mock user ID to context
-		ctx := context.WithValue(r.Context(), UserIDKey, "mock-user-id")
+		ctx := context.WithValue(r.Context(), UserIDKey, MockUserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -68,3 +72,18 @@ func GetUserID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// ClientID identifies the caller of r for per-client state. It is the
+// authenticated user ID, or the client IP when there is none or it is
+// MockUserID, which cannot tell callers apart. RealIP must run first for
+// the IP to be the client's rather than a proxy's.
+func ClientID(r *http.Request) string {
+	if userID := GetUserID(r.Context()); userID != "" && userID != MockUserID {
+		return "user:" + userID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+)
+
+// concurrencyBody is the JSON error written when a user is over the limit
+const concurrencyBody = `{"error":"too many concurrent requests","code":"TOO_MANY_REQUESTS"}`
+
+// ConcurrencyPerUser caps the requests each authenticated user may have in
+// flight at once, replying 429 to requests over max so one client cannot
+// monopolize the service. Callers are told apart by ClientID, so it must run
+// after authentication; while JWTAuth is the mock, that is by client IP.
+func ConcurrencyPerUser(max int) func(http.Handler) http.Handler {
+	l := &userLimiter{max: max, inFlight: make(map[string]int)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := ClientID(r)
+			if !l.acquire(user) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(concurrencyBody))
+				return
+			}
+			defer l.release(user)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// userLimiter counts in-flight requests per user. Counters are created on
+// first use and dropped once idle so the map only holds active users.
+type userLimiter struct {
+	max int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// acquire counts a request for user and reports false, without counting
+// it, if user already has max requests in flight
+func (l *userLimiter) acquire(user string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[user] >= l.max {
+		return false
+	}
+	l.inFlight[user]++
+	return true
+}
+
+// release uncounts a request acquired for user
+func (l *userLimiter) release(user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[user]--; l.inFlight[user] <= 0 {
+		delete(l.inFlight, user)
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyPerUser(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("block") == "true" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	limited := ConcurrencyPerUser(2)(blocking)
+
+	serve := func(user, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/companies"+query, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, user))
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Saturate alice with two requests that stay in flight
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusNoContent, serve("alice", "?block=true").Code)
+		}()
+		<-started
+	}
+
+	rec := serve("alice", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"too many concurrent requests","code":"TOO_MANY_REQUESTS"}`, rec.Body.String())
+
+	// Another user is unaffected
+	assert.Equal(t, http.StatusNoContent, serve("bob", "").Code)
+
+	// Alice's slots free up once her requests finish
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusNoContent, serve("alice", "").Code)
+}
+
+func TestConcurrencyPerUser_MockAuth(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+	// Every request carries the mock user ID, as behind the mock JWTAuth
+	limited := ConcurrencyPerUser(1)(blocking)
+	serve := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/companies", nil)
+		req.RemoteAddr = remoteAddr
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, MockUserID))
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("192.0.2.1:1234") }()
+	<-started
+
+	// Another client is not limited by the first one's request
+	go func() { done <- serve("192.0.2.2:1234") }()
+	<-started
+	// The same client from another port is
+	assert.Equal(t, http.StatusTooManyRequests, serve("192.0.2.1:5678"))
+
+	close(release)
+	assert.Equal(t, http.StatusNoContent, <-done)
+	assert.Equal(t, http.StatusNoContent, <-done)
+}
+
+func TestUserLimiter(t *testing.T) {
+	l := &userLimiter{max: 1, inFlight: make(map[string]int)}
+
+	assert.True(t, l.acquire("alice"))
+	assert.False(t, l.acquire("alice"))
+	assert.True(t, l.acquire("bob"))
+
+	l.release("alice")
+	l.release("bob")
+	assert.Empty(t, l.inFlight, "idle users are dropped")
+	assert.True(t, l.acquire("alice"))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"xm-company-service/internal/core"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// CorrelationIDHeader carries a caller-chosen correlation ID
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength bounds a caller-supplied correlation ID
+const maxCorrelationIDLength = 128
+
+// CorrelationID stores a correlation ID in the request context for events
+// and echoes it in the response. A caller's X-Correlation-ID is kept;
+// otherwise the chi request ID is used, so it must run after RequestID.
+func CorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CorrelationIDHeader)
+		if id == "" || len(id) > maxCorrelationIDLength {
+			id = chimiddleware.GetReqID(r.Context())
+		}
+		if id != "" {
+			w.Header().Set(CorrelationIDHeader, id)
+		}
+
+		next.ServeHTTP(w, r.WithContext(core.WithCorrelationID(r.Context(), id)))
+	})
+}
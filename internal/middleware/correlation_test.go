@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"xm-company-service/internal/core"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelationID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   func(requestID string) string
+	}{
+		{"caller's ID is kept", "order-42", func(string) string { return "order-42" }},
+		{"request ID by default", "", func(requestID string) string { return requestID }},
+		{"oversized ID is replaced", strings.Repeat("x", maxCorrelationIDLength+1), func(requestID string) string { return requestID }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, requestID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = core.CorrelationID(r.Context())
+				requestID = chimiddleware.GetReqID(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/companies", nil)
+			if tt.header != "" {
+				req.Header.Set(CorrelationIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			chimiddleware.RequestID(CorrelationID(next)).ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, got)
+			assert.Equal(t, tt.want(requestID), got)
+			assert.Equal(t, got, rec.Header().Get(CorrelationIDHeader))
+		})
+	}
+}
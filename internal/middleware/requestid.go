@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultRequestIDHeader is chi's request ID header
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds a caller-supplied request ID
+const maxRequestIDLength = 128
+
+// RequestID is chi's RequestID with a configurable header. A caller's ID in
+// header is reused as the request ID, which the access log prints and
+// CorrelationID falls back to for events; otherwise chi's RequestID takes
+// over, honouring its own X-Request-Id or generating one. The ID is echoed
+// in header on the response.
+func RequestID(header string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(header, chimiddleware.GetReqID(r.Context()))
+			next.ServeHTTP(w, r)
+		})
+		generate := chimiddleware.RequestID(echo)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if len(id) > maxRequestIDLength {
+				// Drop it so chi does not reuse it when header is its own
+				r = r.Clone(r.Context())
+				r.Header.Del(header)
+				id = ""
+			}
+			if id == "" {
+				generate.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), chimiddleware.RequestIDKey, id)
+			echo.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"xm-company-service/internal/core"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	// serve runs a request through the request ID, access log and
+	// correlation middleware, returning the response, the ID seen by
+	// events and the access log output
+	serve := func(header string, reqHeaders map[string]string) (*httptest.ResponseRecorder, string, string) {
+		var logs bytes.Buffer
+		accessLog := chimiddleware.RequestLogger(&chimiddleware.DefaultLogFormatter{
+			Logger: log.New(&logs, "", 0), NoColor: true,
+		})
+		var eventID string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			eventID = core.CorrelationID(r.Context())
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/companies", nil)
+		for key, value := range reqHeaders {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		RequestID(header)(accessLog(CorrelationID(next))).ServeHTTP(rec, req)
+		return rec, eventID, logs.String()
+	}
+
+	t.Run("custom header is reused", func(t *testing.T) {
+		rec, eventID, logs := serve("X-Trace-Id", map[string]string{"X-Trace-Id": "trace-42"})
+
+		assert.Equal(t, "trace-42", rec.Header().Get("X-Trace-Id"))
+		assert.Equal(t, "trace-42", eventID)
+		assert.Contains(t, logs, "[trace-42]")
+	})
+
+	t.Run("generated when absent", func(t *testing.T) {
+		rec, eventID, logs := serve("X-Trace-Id", nil)
+
+		id := rec.Header().Get("X-Trace-Id")
+		assert.NotEmpty(t, id)
+		assert.Equal(t, id, eventID)
+		assert.Contains(t, logs, "["+id+"]")
+	})
+
+	t.Run("default header", func(t *testing.T) {
+		rec, eventID, _ := serve(DefaultRequestIDHeader, map[string]string{DefaultRequestIDHeader: "req-7"})
+
+		assert.Equal(t, "req-7", rec.Header().Get(DefaultRequestIDHeader))
+		assert.Equal(t, "req-7", eventID)
+	})
+
+	t.Run("oversized ID is replaced", func(t *testing.T) {
+		long := strings.Repeat("x", maxRequestIDLength+1)
+		rec, _, _ := serve(DefaultRequestIDHeader, map[string]string{DefaultRequestIDHeader: long})
+
+		id := rec.Header().Get(DefaultRequestIDHeader)
+		assert.NotEmpty(t, id)
+		assert.NotEqual(t, long, id)
+	})
+}
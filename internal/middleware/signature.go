@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers carrying a request signature, see Sign
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+)
+
+// DefaultSignatureWindow is how far a signed timestamp may drift from the
+// server clock, and how long nonces are remembered
+const DefaultSignatureWindow = 5 * time.Minute
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.nonce.body" under secret,
+// the value machine callers send in X-Signature. timestamp is in Unix
+// seconds.
+func Sign(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequestSignature verifies requests signed with the shared secret, see
+// Sign. A missing or wrong signature, or a timestamp more than window from
+// now, is rejected with 401. A nonce already used within the window is a
+// replay and is rejected with 409. Nonces are remembered in memory, so
+// replays are only caught by the replica that saw the original request.
+// The body is buffered to verify it, so one over maxBody bytes is rejected
+// with 413 without being read in full.
+func RequestSignature(secret string, window time.Duration, maxBody int64) func(http.Handler) http.Handler {
+	nonces := &nonceCache{seen: make(map[string]time.Time)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timestamp := r.Header.Get(SignatureTimestampHeader)
+			nonce := r.Header.Get(SignatureNonceHeader)
+			signature := r.Header.Get(SignatureHeader)
+			if timestamp == "" || nonce == "" || signature == "" {
+				http.Error(w, `{"error": "missing request signature"}`, http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, `{"error": "request body too large"}`, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, `{"error": "failed to read request body"}`, http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, nonce, body))) {
+				http.Error(w, `{"error": "invalid request signature"}`, http.StatusUnauthorized)
+				return
+			}
+
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			signed := time.Unix(seconds, 0)
+			now := time.Now()
+			if err != nil || signed.Before(now.Add(-window)) || signed.After(now.Add(window)) {
+				http.Error(w, `{"error": "request signature expired"}`, http.StatusUnauthorized)
+				return
+			}
+
+			if !nonces.add(nonce, signed.Add(window), now) {
+				http.Error(w, `{"error": "request already processed"}`, http.StatusConflict)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// nonceCache remembers nonces until their signature would have expired
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextPrune time.Time
+}
+
+// add records nonce until expires and reports false if it is already
+// recorded
+func (c *nonceCache) add(nonce string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextPrune) {
+		for n, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, n)
+			}
+		}
+		c.nextPrune = now.Add(time.Minute)
+	}
+
+	if exp, ok := c.seen[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	c.seen[nonce] = expires
+	return true
+}
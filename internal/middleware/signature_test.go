@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestSignature(t *testing.T) {
+	const secret = "webhook-secret"
+
+	var received string
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	signed := RequestSignature(secret, time.Minute, 64)(echo)
+
+	// send signs body with signedAt and nonce, then sends sentBody instead
+	send := func(body, sentBody, nonce string, signedAt time.Time) int {
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		req := httptest.NewRequest(http.MethodPatch, "/companies", strings.NewReader(sentBody))
+		req.Header.Set(SignatureHeader, Sign(secret, timestamp, nonce, []byte(body)))
+		req.Header.Set(SignatureTimestampHeader, timestamp)
+		req.Header.Set(SignatureNonceHeader, nonce)
+		rec := httptest.NewRecorder()
+		signed.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		code := send(`{"employees":5}`, `{"employees":5}`, "nonce-1", time.Now())
+
+		assert.Equal(t, http.StatusNoContent, code)
+		assert.Equal(t, `{"employees":5}`, received, "body is still readable downstream")
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		code := send(`{"employees":5}`, `{"employees":500}`, "nonce-2", time.Now())
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("replayed nonce", func(t *testing.T) {
+		now := time.Now()
+		assert.Equal(t, http.StatusNoContent, send(`{}`, `{}`, "nonce-3", now))
+		assert.Equal(t, http.StatusConflict, send(`{}`, `{}`, "nonce-3", now))
+	})
+
+	t.Run("rejected nonce can be reused", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, send(`{}`, `{"x":1}`, "nonce-4", time.Now()))
+		assert.Equal(t, http.StatusNoContent, send(`{}`, `{}`, "nonce-4", time.Now()))
+	})
+
+	t.Run("timestamp outside the window", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, send(`{}`, `{}`, "nonce-5", time.Now().Add(-2*time.Minute)))
+		assert.Equal(t, http.StatusUnauthorized, send(`{}`, `{}`, "nonce-6", time.Now().Add(2*time.Minute)))
+	})
+
+	t.Run("body over the limit", func(t *testing.T) {
+		large := `{"description":"` + strings.Repeat("x", 64) + `"}`
+		assert.Equal(t, http.StatusRequestEntityTooLarge, send(large, large, "nonce-7", time.Now()))
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		signed.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/companies", strings.NewReader(`{}`)))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestNonceCache(t *testing.T) {
+	c := &nonceCache{seen: make(map[string]time.Time)}
+	now := time.Now()
+
+	assert.True(t, c.add("a", now.Add(time.Minute), now))
+	assert.False(t, c.add("a", now.Add(time.Minute), now.Add(30*time.Second)))
+
+	// Expired nonces are forgotten and pruned
+	later := now.Add(2 * time.Minute)
+	assert.True(t, c.add("a", later.Add(time.Minute), later))
+	assert.True(t, c.add("b", later.Add(time.Minute), later))
+	assert.Len(t, c.seen, 2)
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Run("slow handler gets a JSON 503", func(t *testing.T) {
+		release := make(chan struct{})
+		lateWrite := make(chan error, 1)
+		slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusOK)
+			_, err := w.Write([]byte("too late"))
+			lateWrite <- err
+		})
+
+		rec := httptest.NewRecorder()
+		Timeout(10*time.Millisecond)(slow).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/companies", nil))
+		close(release)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"error":"request timed out","code":"TIMEOUT"}`, rec.Body.String())
+
+		// The handler's own response is dropped, not appended
+		assert.ErrorIs(t, <-lateWrite, http.ErrHandlerTimeout)
+		assert.NotContains(t, rec.Body.String(), "too late")
+	})
+
+	t.Run("fast handler response passes through", func(t *testing.T) {
+		fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", "/companies/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"1"}`))
+		})
+
+		rec := httptest.NewRecorder()
+		Timeout(time.Second)(fast).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/companies", nil))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/companies/1", rec.Header().Get("Location"))
+		assert.Equal(t, `{"id":"1"}`, rec.Body.String())
+	})
+
+	t.Run("panics reach the serving goroutine", func(t *testing.T) {
+		boom := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+
+		assert.PanicsWithValue(t, "boom", func() {
+			Timeout(time.Second)(boom).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+}
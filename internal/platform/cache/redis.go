@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"xm-company-service/internal/core"
+
+	"github.com/google/uuid"
+)
+
+// RemoteStore is the subset of a Redis client used by RedisRepository.
+// Get returns a nil value and no error when the key does not exist.
+type RemoteStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX stores value only if key does not exist and reports whether it
+	// did
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// DelPrefix deletes every key starting with prefix and returns the
+	// number deleted
+	DelPrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// invalidatedMarker replaces a company's entry for invalidationHold after
+// a mutation. Lookups treat it as a miss, and since entries are only
+// written if the key is absent, a lookup that read the row before the
+// mutation cannot put it back while the marker is held.
+var invalidatedMarker = []byte("invalidated")
+
+// invalidationHold is how long invalidatedMarker blocks cache writes
+const invalidationHold = 5 * time.Second
+
+// RedisRepository decorates a core.Repository with a shared cache for
+// GetByID so every replica sees the same invalidations. Cache failures are
+// logged and the call falls through to the wrapped repository.
+//
+// A stale entry can survive a mutation, for at most the cache TTL, in two
+// cases: a lookup takes longer than invalidationHold between reading the
+// row and caching it, or the invalidation itself fails.
+type RedisRepository struct {
+	core.Repository
+
+	store RemoteStore
+	ttl   time.Duration
+}
+
+// NewRedisRepository wraps next with a remote cache holding companies for ttl
+func NewRedisRepository(next core.Repository, store RemoteStore, ttl time.Duration) *RedisRepository {
+	return &RedisRepository{
+		Repository: next,
+		store:      store,
+		ttl:        ttl,
+	}
+}
+
+// GetByID returns the cached company or loads it from the wrapped repository
+func (r *RedisRepository) GetByID(ctx context.Context, id uuid.UUID) (*core.Company, error) {
+	key := companyKey(id)
+
+	value, err := r.store.Get(ctx, key)
+	switch {
+	case err != nil:
+		log.Printf("Warning: cache read failed for %s: %v", key, err)
+	case bytes.Equal(value, invalidatedMarker):
+		// Recently mutated: read through, and the write below is refused
+	case value != nil:
+		var c core.Company
+		if err := json.Unmarshal(value, &c); err == nil {
+			return &c, nil
+		}
+		log.Printf("Warning: discarding undecodable cache entry %s", key)
+	}
+
+	c, err := r.Repository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			r.invalidate(ctx, id)
+		}
+		return nil, err
+	}
+
+	if value, err := json.Marshal(c); err == nil {
+		if _, err := r.store.SetNX(ctx, key, value, r.ttl); err != nil {
+			log.Printf("Warning: cache write failed for %s: %v", key, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Update persists the company and invalidates its cache key
+func (r *RedisRepository) Update(ctx context.Context, c *core.Company) error {
+	defer r.invalidate(ctx, c.ID)
+	return r.Repository.Update(ctx, c)
+}
+
+// UpdateMany persists the companies and invalidates their cache keys
+func (r *RedisRepository) UpdateMany(ctx context.Context, companies []*core.Company) error {
+	defer func() {
+		for _, c := range companies {
+			r.invalidate(ctx, c.ID)
+		}
+	}()
+	return r.Repository.UpdateMany(ctx, companies)
+}
+
+// Delete removes the company and invalidates its cache key
+func (r *RedisRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.Delete(ctx, id)
+}
+
+// FlushCache deletes every company key and returns how many were deleted.
+// Unlike invalidation, a failure is returned so the caller knows stale
+// entries may remain.
+func (r *RedisRepository) FlushCache(ctx context.Context) (int, error) {
+	return r.store.DelPrefix(ctx, companyKeyPrefix)
+}
+
+// invalidate replaces the company's entry with invalidatedMarker
+func (r *RedisRepository) invalidate(ctx context.Context, id uuid.UUID) {
+	if err := r.store.Set(ctx, companyKey(id), invalidatedMarker, invalidationHold); err != nil {
+		log.Printf("Warning: cache invalidation failed for %s: %v", companyKey(id), err)
+	}
+}
+
+// companyKeyPrefix starts every company cache key
+const companyKeyPrefix = "company:"
+
+// companyKey is the cache key for a company
+func companyKey(id uuid.UUID) string {
+	return companyKeyPrefix + id.String()
+}
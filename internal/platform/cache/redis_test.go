@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"xm-company-service/internal/core"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStore is a mock implementation of RemoteStore
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) Get(ctx context.Context, key string) ([]byte, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := m.Called(ctx, key, value, ttl)
+	return args.Error(0)
+}
+
+func (m *MockStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, key, value, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	args := m.Called(ctx, prefix)
+	return args.Int(0), args.Error(1)
+}
+
+func TestRedisRepository_GetByID(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hit is served from the store", func(t *testing.T) {
+		next := new(MockRepository)
+		store := new(MockStore)
+		repo := NewRedisRepository(next, store, time.Minute)
+
+		id := uuid.New()
+		cached, _ := json.Marshal(core.Company{ID: id, Name: "CachedCo"})
+		store.On("Get", ctx, companyKey(id)).Return(cached, nil)
+
+		got, err := repo.GetByID(ctx, id)
+
+		require.NoError(t, err)
+		assert.Equal(t, "CachedCo", got.Name)
+		next.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("miss loads and populates the store", func(t *testing.T) {
+		next := new(MockRepository)
+		store := new(MockStore)
+		repo := NewRedisRepository(next, store, time.Minute)
+
+		id := uuid.New()
+		company := &core.Company{ID: id, Name: "LoadedCo"}
+		encoded, _ := json.Marshal(company)
+		store.On("Get", ctx, companyKey(id)).Return(nil, nil)
+		next.On("GetByID", ctx, id).Return(company, nil)
+		store.On("SetNX", ctx, companyKey(id), encoded, time.Minute).Return(true, nil)
+
+		got, err := repo.GetByID(ctx, id)
+
+		require.NoError(t, err)
+		assert.Equal(t, company, got)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("store down falls through to the repository", func(t *testing.T) {
+		next := new(MockRepository)
+		store := new(MockStore)
+		repo := NewRedisRepository(next, store, time.Minute)
+
+		id := uuid.New()
+		company := &core.Company{ID: id, Name: "LoadedCo"}
+		store.On("Get", ctx, companyKey(id)).Return(nil, errors.New("connection refused"))
+		store.On("SetNX", ctx, companyKey(id), mock.Anything, time.Minute).Return(false, errors.New("connection refused"))
+		next.On("GetByID", ctx, id).Return(company, nil)
+
+		got, err := repo.GetByID(ctx, id)
+
+		require.NoError(t, err)
+		assert.Equal(t, company, got)
+	})
+}
+
+func TestRedisRepository_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("update marks the key invalidated", func(t *testing.T) {
+		next := new(MockRepository)
+		store := new(MockStore)
+		repo := NewRedisRepository(next, store, time.Minute)
+
+		company := &core.Company{ID: uuid.New(), Name: "NewName"}
+		next.On("Update", ctx, company).Return(nil)
+		store.On("Set", ctx, companyKey(company.ID), invalidatedMarker, invalidationHold).Return(nil)
+
+		require.NoError(t, repo.Update(ctx, company))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("delete invalidates the key even if the store is down", func(t *testing.T) {
+		next := new(MockRepository)
+		store := new(MockStore)
+		repo := NewRedisRepository(next, store, time.Minute)
+
+		id := uuid.New()
+		next.On("Delete", ctx, id).Return(nil)
+		store.On("Set", ctx, companyKey(id), invalidatedMarker, invalidationHold).Return(errors.New("connection refused"))
+
+		require.NoError(t, repo.Delete(ctx, id))
+		store.AssertExpectations(t)
+	})
+}
+
+// memStore is an in-memory RemoteStore ignoring TTLs
+type memStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *memStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *memStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		return false, nil
+	}
+	s.data[key] = value
+	return true, nil
+}
+
+func (s *memStore) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	return 0, nil
+}
+
+func TestRedisRepository_LookupRacingUpdate(t *testing.T) {
+	ctx := context.Background()
+	next := new(MockRepository)
+	store := &memStore{data: make(map[string][]byte)}
+	repo := NewRedisRepository(next, store, time.Minute)
+
+	id := uuid.New()
+	readOld := make(chan struct{})
+	updated := make(chan struct{})
+
+	// The lookup reads the old row, then stalls until the update has
+	// committed and invalidated the key
+	next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "OldName"}, nil).
+		Run(func(mock.Arguments) {
+			close(readOld)
+			<-updated
+		}).Once()
+	next.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+	next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "NewName"}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := repo.GetByID(ctx, id)
+		assert.NoError(t, err)
+	}()
+
+	<-readOld
+	require.NoError(t, repo.Update(ctx, &core.Company{ID: id, Name: "NewName"}))
+	close(updated)
+	<-done
+
+	// The stalled lookup did not put the old row back
+	got, err := repo.GetByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "NewName", got.Name)
+}
+
+func TestRedisRepository_FlushCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deletes every company key", func(t *testing.T) {
+		store := new(MockStore)
+		repo := NewRedisRepository(new(MockRepository), store, time.Minute)
+		store.On("DelPrefix", ctx, "company:").Return(3, nil)
+
+		flushed, err := repo.FlushCache(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, flushed)
+	})
+
+	t.Run("store failure is returned", func(t *testing.T) {
+		store := new(MockStore)
+		repo := NewRedisRepository(new(MockRepository), store, time.Minute)
+		store.On("DelPrefix", ctx, "company:").Return(1, errors.New("connection reset"))
+
+		flushed, err := repo.FlushCache(ctx)
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, flushed)
+	})
+}
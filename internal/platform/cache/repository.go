@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"xm-company-service/internal/core"
+
+	"github.com/google/uuid"
+)
+
+// Repository decorates a core.Repository with an in-memory LRU cache for
+// GetByID. Entries are invalidated on Update and Delete of the same ID.
+type Repository struct {
+	core.Repository
+
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[uuid.UUID]*list.Element
+	// gen is bumped on every invalidation so a lookup that raced with a
+	// mutation does not store the value it read before the mutation
+	gen uint64
+	now func() time.Time
+}
+
+type entry struct {
+	id      uuid.UUID
+	company core.Company
+	expires time.Time
+}
+
+// NewRepository wraps next with an LRU cache holding up to size companies for ttl
+func NewRepository(next core.Repository, size int, ttl time.Duration) *Repository {
+	return &Repository{
+		Repository: next,
+		size:       size,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      make(map[uuid.UUID]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// GetByID returns the cached company or loads it from the wrapped repository
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*core.Company, error) {
+	r.mu.Lock()
+	if c, ok := r.lookup(id); ok {
+		r.mu.Unlock()
+		return c, nil
+	}
+	gen := r.gen
+	r.mu.Unlock()
+
+	c, err := r.Repository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			r.Invalidate(id)
+		}
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.gen == gen {
+		r.store(c)
+	}
+	r.mu.Unlock()
+
+	return c, nil
+}
+
+// Update persists the company and evicts its cache entry
+func (r *Repository) Update(ctx context.Context, c *core.Company) error {
+	defer r.Invalidate(c.ID)
+	return r.Repository.Update(ctx, c)
+}
+
+// UpdateMany persists the companies and evicts their cache entries
+func (r *Repository) UpdateMany(ctx context.Context, companies []*core.Company) error {
+	defer func() {
+		for _, c := range companies {
+			r.Invalidate(c.ID)
+		}
+	}()
+	return r.Repository.UpdateMany(ctx, companies)
+}
+
+// Delete removes the company and evicts its cache entry
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer r.Invalidate(id)
+	return r.Repository.Delete(ctx, id)
+}
+
+// Invalidate evicts the cache entry for id, if any
+func (r *Repository) Invalidate(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gen++
+	if el, ok := r.items[id]; ok {
+		r.remove(el)
+	}
+}
+
+// FlushCache evicts every entry and returns how many were evicted
+func (r *Repository) FlushCache(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gen++
+	n := r.order.Len()
+	r.order.Init()
+	clear(r.items)
+	return n, nil
+}
+
+// Len returns the number of cached entries
+func (r *Repository) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.order.Len()
+}
+
+// lookup returns a copy of a fresh cached company. Callers must hold mu.
+func (r *Repository) lookup(id uuid.UUID) (*core.Company, bool) {
+	el, ok := r.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if r.now().After(e.expires) {
+		r.remove(el)
+		return nil, false
+	}
+
+	r.order.MoveToFront(el)
+	c := e.company
+	return &c, true
+}
+
+// store caches a copy of c, evicting the least recently used entry when full.
+// Callers must hold mu.
+func (r *Repository) store(c *core.Company) {
+	e := &entry{id: c.ID, company: *c, expires: r.now().Add(r.ttl)}
+
+	if el, ok := r.items[c.ID]; ok {
+		el.Value = e
+		r.order.MoveToFront(el)
+		return
+	}
+
+	r.items[c.ID] = r.order.PushFront(e)
+	for r.order.Len() > r.size {
+		r.remove(r.order.Back())
+	}
+}
+
+// remove drops an element from the list and index. Callers must hold mu.
+func (r *Repository) remove(el *list.Element) {
+	r.order.Remove(el)
+	delete(r.items, el.Value.(*entry).id)
+}
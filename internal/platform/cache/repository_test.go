@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"xm-company-service/internal/core"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRepository is a mock implementation of core.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, company *core.Company) error {
+	args := m.Called(ctx, company)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetByID(ctx context.Context, id uuid.UUID) (*core.Company, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*core.Company), args.Error(1)
+}
+
+func (m *MockRepository) GetByName(ctx context.Context, name string) (*core.Company, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*core.Company), args.Error(1)
+}
+
+func (m *MockRepository) Update(ctx context.Context, company *core.Company) error {
+	args := m.Called(ctx, company)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UpdateMany(ctx context.Context, companies []*core.Company) error {
+	args := m.Called(ctx, companies)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) EmployeeAggregates(ctx context.Context, companyType core.CompanyType) (*core.EmployeeAggregates, error) {
+	args := m.Called(ctx, companyType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*core.EmployeeAggregates), args.Error(1)
+}
+
+func (m *MockRepository) SearchByName(ctx context.Context, q string, limit, offset int) ([]*core.Company, error) {
+	args := m.Called(ctx, q, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.Company), args.Error(1)
+}
+
+func (m *MockRepository) EmployeeDistribution(ctx context.Context, bounds []int) ([]core.EmployeeBucket, error) {
+	args := m.Called(ctx, bounds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]core.EmployeeBucket), args.Error(1)
+}
+
+func (m *MockRepository) FindSimilarNames(ctx context.Context, name string) ([]*core.CompanyName, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.CompanyName), args.Error(1)
+}
+
+func (m *MockRepository) NamePrefixSearch(ctx context.Context, prefix string, limit int) ([]*core.CompanyName, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.CompanyName), args.Error(1)
+}
+
+func (m *MockRepository) Count(ctx context.Context, companyType core.CompanyType) (int, error) {
+	args := m.Called(ctx, companyType)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ApproxCount(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func TestRepository_GetByID(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("second read is served from cache", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 10, time.Minute)
+
+		id := uuid.New()
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "CachedCo"}, nil).Once()
+
+		first, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		second, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		next.AssertNumberOfCalls(t, "GetByID", 1)
+	})
+
+	t.Run("callers cannot mutate the cached entry", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 10, time.Minute)
+
+		id := uuid.New()
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "CachedCo"}, nil).Once()
+
+		first, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		first.Name = "Mutated"
+
+		second, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "CachedCo", second.Name)
+	})
+
+	t.Run("expired entries are reloaded", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 10, time.Minute)
+		now := time.Now()
+		repo.now = func() time.Time { return now }
+
+		id := uuid.New()
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "CachedCo"}, nil)
+
+		_, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		now = now.Add(2 * time.Minute)
+		_, err = repo.GetByID(ctx, id)
+		require.NoError(t, err)
+
+		next.AssertNumberOfCalls(t, "GetByID", 2)
+	})
+
+	t.Run("least recently used entry is evicted", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 2, time.Minute)
+
+		a, b, c := uuid.New(), uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{a, b, c} {
+			next.On("GetByID", ctx, id).Return(&core.Company{ID: id}, nil)
+		}
+
+		for _, id := range []uuid.UUID{a, b, a, c} {
+			_, err := repo.GetByID(ctx, id)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, 2, repo.Len())
+		_, err := repo.GetByID(ctx, b)
+		require.NoError(t, err)
+		next.AssertNumberOfCalls(t, "GetByID", 4) // a, b, c, then b again
+	})
+}
+
+func TestRepository_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("read after update reflects the change", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 10, time.Minute)
+
+		id := uuid.New()
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "OldName"}, nil).Once()
+		next.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "NewName"}, nil).Once()
+
+		_, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.NoError(t, repo.Update(ctx, &core.Company{ID: id, Name: "NewName"}))
+
+		got, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "NewName", got.Name)
+	})
+
+	t.Run("read after batch update reflects the change", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 10, time.Minute)
+
+		id := uuid.New()
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "OldName"}, nil).Once()
+		next.On("UpdateMany", ctx, mock.Anything).Return(nil)
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "NewName"}, nil).Once()
+
+		_, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.NoError(t, repo.UpdateMany(ctx, []*core.Company{{ID: id, Name: "NewName"}}))
+
+		got, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "NewName", got.Name)
+	})
+
+	t.Run("deleted company is evicted", func(t *testing.T) {
+		next := new(MockRepository)
+		repo := NewRepository(next, 10, time.Minute)
+
+		id := uuid.New()
+		next.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "Doomed"}, nil).Once()
+		next.On("Delete", ctx, id).Return(nil)
+		next.On("GetByID", ctx, id).Return(nil, core.ErrNotFound).Once()
+
+		_, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, id))
+
+		_, err = repo.GetByID(ctx, id)
+		assert.ErrorIs(t, err, core.ErrNotFound)
+		assert.Equal(t, 0, repo.Len())
+	})
+}
+
+func TestRepository_FlushCache(t *testing.T) {
+	ctx := context.Background()
+	next := new(MockRepository)
+	repo := NewRepository(next, 10, time.Minute)
+
+	a, b := uuid.New(), uuid.New()
+	next.On("GetByID", ctx, a).Return(&core.Company{ID: a, Name: "OldName"}, nil).Once()
+	next.On("GetByID", ctx, b).Return(&core.Company{ID: b}, nil).Once()
+	next.On("GetByID", ctx, a).Return(&core.Company{ID: a, Name: "FixedName"}, nil).Once()
+
+	for _, id := range []uuid.UUID{a, b} {
+		_, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+	}
+
+	flushed, err := repo.FlushCache(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, flushed)
+	assert.Equal(t, 0, repo.Len())
+
+	got, err := repo.GetByID(ctx, a)
+	require.NoError(t, err)
+	assert.Equal(t, "FixedName", got.Name)
+	next.AssertNumberOfCalls(t, "GetByID", 3)
+}
@@ -3,46 +3,400 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"xm-company-service/internal/core"
+
+	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 )
 
+// Event formats supported by the producer
+const (
+	FormatNative      = "native"
+	FormatCloudEvents = "cloudevents"
+)
+
+// DefaultCloudEventsSource is the CloudEvents source used when none is configured
+const DefaultCloudEventsSource = "xm-company-service"
+
+// IsValidFormat reports whether format is a supported event format
+func IsValidFormat(format string) bool {
+	return format == FormatNative || format == FormatCloudEvents
+}
+
+// Headers set on every event message, derived from the event
+const (
+	HeaderEventType     = "event-type"
+	HeaderCompanyID     = "company-id"
+	HeaderCorrelationID = "correlation-id"
+)
+
+// ParseHeaders parses KAFKA_MESSAGE_HEADERS, a comma-separated list of
+// key=value pairs added to every message. Keys must be unique and must not
+// shadow the headers the producer derives from each event.
+func ParseHeaders(raw string) ([]kafka.Header, error) {
+	var headers []kafka.Header
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("header %q is not key=value", pair)
+		}
+		switch {
+		case key == HeaderEventType || key == HeaderCompanyID || key == HeaderCorrelationID:
+			return nil, fmt.Errorf("header %q is set by the producer", key)
+		case seen[key]:
+			return nil, fmt.Errorf("header %q is repeated", key)
+		}
+		seen[key] = true
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(strings.TrimSpace(value))})
+	}
+	return headers, nil
+}
+
+// eventFields are the company fields KAFKA_EVENT_FIELDS may select
+var eventFields = map[string]bool{
+	"id": true, "name": true, "description": true, "employees": true, "registered": true, "type": true,
+	"createdAt": true,
+}
+
+// ParseEventFields parses KAFKA_EVENT_FIELDS, a comma-separated allow-list
+// of company fields to publish. An empty list publishes whole payloads.
+func ParseEventFields(raw string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !eventFields[field] {
+			return nil, fmt.Errorf("unknown company field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// TopicFormat routes events to a topic in one event format. Compacted
+// topics also receive delete tombstones, see WithDeleteTombstones.
+type TopicFormat struct {
+	Topic     string
+	Format    string
+	Compacted bool
+}
+
+// compactedSuffix marks a KAFKA_TOPICS entry as a log-compacted topic
+const compactedSuffix = "compacted"
+
+// ParseTopicFormats parses KAFKA_TOPICS, a comma-separated list of
+// topic:format pairs such as v1:native,v2:cloudevents:compacted. Each event
+// is published to every topic, encoded in that topic's format; a trailing
+// :compacted marks a log-compacted topic.
+func ParseTopicFormats(raw string) ([]TopicFormat, error) {
+	var sinks []TopicFormat
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		topic, format, ok := strings.Cut(pair, ":")
+		format, marker, compacted := strings.Cut(format, ":")
+		topic, format, marker = strings.TrimSpace(topic), strings.TrimSpace(format), strings.TrimSpace(marker)
+		switch {
+		case !ok || topic == "":
+			return nil, fmt.Errorf("topic %q is not topic:format", pair)
+		case compacted && marker != compactedSuffix:
+			return nil, fmt.Errorf("topic %q has unknown marker %q (want %s)", topic, marker, compactedSuffix)
+		case !IsValidFormat(format):
+			return nil, fmt.Errorf("topic %q has unknown format %q", topic, format)
+		case seen[topic]:
+			return nil, fmt.Errorf("topic %q is repeated", topic)
+		}
+		seen[topic] = true
+		sinks = append(sinks, TopicFormat{Topic: topic, Format: format, Compacted: compacted})
+	}
+	return sinks, nil
+}
+
+// failureThreshold is the number of consecutive write failures after which
+// the producer reports itself unhealthy and refreshes broker metadata
+const failureThreshold = 3
+
+// dialTimeout bounds connecting to a broker, for writes and for the
+// controller requests made at startup and during recovery
+const dialTimeout = 5 * time.Second
+
+// messageWriter is the part of *kafka.Writer used by the producer
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 // Producer implements core.EventProducer for Kafka
 type Producer struct {
-	writer  *kafka.Writer
+	writer  messageWriter
 	enabled bool
+	format  string
+	source  string
+	brokers []string
+	topic   string
+	// tombstones adds a nil-value message after each CompanyDeleted event
+	// so log-compacted topics drop the company
+	tombstones bool
+	// headers are static headers added to every message
+	headers []kafka.Header
+	// fields, when set, restricts company payloads to these fields plus id
+	fields map[string]bool
+	// baseURL, when set, is the public URL that resource URLs are built on
+	baseURL string
+	// autoCreate, when set, is the topic created at startup if missing
+	autoCreate *kafka.TopicConfig
+	// createTopic creates a topic through the cluster controller
+	createTopic func(kafka.TopicConfig) error
+	// sinks, when set, replace topic and format: every event is written to
+	// each sink topic in that sink's format
+	sinks []TopicFormat
+
+	mu       sync.Mutex
+	failures int
+	// refresh re-reads cluster metadata after repeated failures; refreshing
+	// keeps at most one refresh in flight
+	refresh    func() error
+	refreshing atomic.Bool
+	// probe checks that the cluster answers before the writer's
+	// connections are dropped
+	probe func(ctx context.Context) error
+	// transport holds the writer's pooled connections and cached metadata
+	transport idleCloser
+}
+
+// idleCloser is the part of *kafka.Transport used to reset connections
+type idleCloser interface {
+	CloseIdleConnections()
+}
+
+// Option configures a Producer
+type Option func(*Producer)
+
+// WithFormat selects the event encoding; source is used for CloudEvents only
+func WithFormat(format, source string) Option {
+	return func(p *Producer) {
+		p.format = format
+		p.source = source
+	}
+}
+
+// WithDeleteTombstones follows every CompanyDeleted event with a tombstone:
+// a message keyed by the company ID with a nil value. With WithTopicFormats
+// only the sinks marked Compacted receive tombstones.
+func WithDeleteTombstones(enabled bool) Option {
+	return func(p *Producer) {
+		p.tombstones = enabled
+	}
+}
+
+// WithHeaders adds static headers to every message, see ParseHeaders
+func WithHeaders(headers []kafka.Header) Option {
+	return func(p *Producer) {
+		p.headers = headers
+	}
+}
+
+// WithEventFields publishes only the given company fields, always including
+// id, see ParseEventFields. CompanyUpdated changes are restricted likewise.
+func WithEventFields(fields []string) Option {
+	return func(p *Producer) {
+		if len(fields) == 0 {
+			p.fields = nil
+			return
+		}
+		p.fields = map[string]bool{"id": true}
+		for _, field := range fields {
+			p.fields[field] = true
+		}
+	}
+}
+
+// WithResourceBaseURL adds the company's URL, baseURL + /companies/{id},
+// to CompanyCreated and CompanyUpdated events so consumers can fetch it
+func WithResourceBaseURL(baseURL string) Option {
+	return func(p *Producer) {
+		p.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTopicAutoCreate creates the topic at startup when it does not exist,
+// for clusters that do not auto-create topics on first write
+func WithTopicAutoCreate(partitions, replicationFactor int) Option {
+	return func(p *Producer) {
+		p.autoCreate = &kafka.TopicConfig{
+			NumPartitions:     partitions,
+			ReplicationFactor: replicationFactor,
+		}
+	}
+}
+
+// WithTopicFormats publishes every event to each of the given topics in
+// that topic's format, see ParseTopicFormats. It replaces the topic passed
+// to NewProducer and WithFormat's format; the CloudEvents source still
+// applies.
+func WithTopicFormats(sinks []TopicFormat) Option {
+	return func(p *Producer) {
+		p.sinks = sinks
+	}
 }
 
 // NewProducer creates a new Kafka producer
-func NewProducer(brokers []string, topic string, enabled bool) *Producer {
+func NewProducer(brokers []string, topic string, enabled bool, opts ...Option) *Producer {
 	if !enabled {
 		log.Println("Kafka producer disabled")
 		return &Producer{enabled: false}
 	}
 
-	writer := &kafka.Writer{
+	p := &Producer{
+		enabled: true,
+		format:  FormatNative,
+		source:  DefaultCloudEventsSource,
+		brokers: brokers,
+		topic:   topic,
+	}
+	p.refresh = p.refreshMetadata
+	p.probe = p.probeController
+	p.createTopic = p.createTopicOnController
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// Messages name their own topic when there are several sinks; the
+	// writer rejects that if it has a topic of its own
+	writerTopic := topic
+	if len(p.sinks) > 0 {
+		writerTopic = ""
+		p.topic = p.sinkTopics()
+	}
+	// The writer gets its own transport so recovery can reset it without
+	// touching other clients. Hashing the key sends every message for a
+	// company, tombstones included, to the same partition in order.
+	transport := &kafka.Transport{DialTimeout: dialTimeout}
+	p.transport = transport
+	p.writer = &kafka.Writer{
 		Addr:         kafka.TCP(brokers...),
-		Topic:        topic,
-		Balancer:     &kafka.LeastBytes{},
+		Topic:        writerTopic,
+		Balancer:     &kafka.Hash{},
 		BatchSize:    1, // Send immediately for this exercise
 		BatchTimeout: 10 * time.Millisecond,
 		RequiredAcks: kafka.RequireOne,
+		Transport:    transport,
 	}
+	p.ensureTopic()
 
-	log.Printf("Kafka producer initialized: brokers=%v, topic=%s", brokers, topic)
-	return &Producer{
-		writer:  writer,
-		enabled: true,
+	if len(p.sinks) > 0 {
+		log.Printf("Kafka producer initialized: brokers=%v, topics=%v", brokers, p.sinks)
+	} else {
+		log.Printf("Kafka producer initialized: brokers=%v, topic=%s, format=%s", brokers, topic, p.format)
+	}
+	return p
+}
+
+// sinkTopics lists the sink topics for logs and errors
+func (p *Producer) sinkTopics() string {
+	topics := make([]string, len(p.sinks))
+	for i, sink := range p.sinks {
+		topics[i] = sink.Topic
+	}
+	return strings.Join(topics, ",")
+}
+
+// targets are the topic and format pairs each event is written to. An
+// empty topic means the writer's own topic, which is compacted whenever
+// tombstones are enabled.
+func (p *Producer) targets() []TopicFormat {
+	if len(p.sinks) > 0 {
+		return p.sinks
 	}
+	return []TopicFormat{{Format: p.format, Compacted: true}}
 }
 
 // Event represents a company mutation event
 type Event struct {
-	Type      string      `json:"type"`
-	Payload   interface{} `json:"payload"`
-	Timestamp time.Time   `json:"timestamp"`
+	Type        string      `json:"type"`
+	Payload     interface{} `json:"payload"`
+	Timestamp   time.Time   `json:"timestamp"`
+	ResourceURL string      `json:"resource_url,omitempty"`
+}
+
+// CloudEvent is the CloudEvents 1.0 JSON envelope
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	Type            string      `json:"type"`
+	Source          string      `json:"source"`
+	ID              string      `json:"id"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+	// ResourceURL is an extension attribute; CloudEvents attribute names
+	// allow only lowercase letters and digits
+	ResourceURL string `json:"resourceurl,omitempty"`
+}
+
+// encode serializes an event in the configured format
+func (p *Producer) encode(eventType string, payload interface{}, resourceURL string, now time.Time) ([]byte, error) {
+	return p.encodeAs(p.format, eventType, payload, resourceURL, now)
+}
+
+// encodeAs serializes an event in the given format
+func (p *Producer) encodeAs(format, eventType string, payload interface{}, resourceURL string, now time.Time) ([]byte, error) {
+	if format == FormatCloudEvents {
+		return json.Marshal(CloudEvent{
+			SpecVersion:     "1.0",
+			Type:            eventType,
+			Source:          p.source,
+			ID:              uuid.New().String(),
+			Time:            now,
+			DataContentType: "application/json",
+			Data:            payload,
+			ResourceURL:     resourceURL,
+		})
+	}
+
+	return json.Marshal(Event{
+		Type:        eventType,
+		Payload:     payload,
+		Timestamp:   now,
+		ResourceURL: resourceURL,
+	})
+}
+
+// resourceURL is the public URL of the company a CompanyCreated or
+// CompanyUpdated event describes, or "" when no base URL is configured.
+// Deleted companies have no resource to fetch.
+func (p *Producer) resourceURL(payload interface{}) string {
+	if p.baseURL == "" {
+		return ""
+	}
+	if _, deleted := payload.(core.CompanyDeletedEvent); deleted {
+		return ""
+	}
+	id, ok := companyID(payload)
+	if !ok {
+		return ""
+	}
+	return p.baseURL + "/companies/" + id.String()
 }
 
 // Publish sends an event to Kafka
@@ -52,32 +406,288 @@ func (p *Producer) Publish(ctx context.Context, eventType string, payload interf
 		return nil
 	}
 
-	event := Event{
-		Type:      eventType,
-		Payload:   payload,
-		Timestamp: time.Now().UTC(),
-	}
-
-	value, err := json.Marshal(event)
+	data, err := p.project(payload)
 	if err != nil {
 		log.Printf("Failed to marshal event: %v", err)
 		return err
 	}
+	resourceURL, now := p.resourceURL(payload), time.Now().UTC()
+	headers := p.messageHeaders(ctx, eventType, payload)
 
-	msg := kafka.Message{
-		Key:   []byte(eventType),
-		Value: value,
+	var msgs []kafka.Message
+	for _, target := range p.targets() {
+		value, err := p.encodeAs(target.Format, eventType, data, resourceURL, now)
+		if err != nil {
+			log.Printf("Failed to marshal event: %v", err)
+			return err
+		}
+		msgs = append(msgs, kafka.Message{
+			Topic:   target.Topic,
+			Key:     messageKey(eventType, payload),
+			Value:   value,
+			Headers: headers,
+		})
+		if deleted, ok := payload.(core.CompanyDeletedEvent); ok && p.tombstones && target.Compacted {
+			marker := tombstone(deleted.ID)
+			marker.Topic = target.Topic
+			marker.Headers = headers
+			msgs = append(msgs, marker)
+		}
 	}
 
-	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		err = p.topicError(err)
 		log.Printf("Failed to publish event %s: %v", eventType, err)
+		p.recordFailure(err)
 		return err
 	}
 
+	p.recordSuccess()
 	log.Printf("Event published: %s", eventType)
 	return nil
 }
 
+// project restricts a company payload to the configured fields. Other
+// payloads, and all payloads when no fields are configured, pass through.
+func (p *Producer) project(payload interface{}) (interface{}, error) {
+	if p.fields == nil {
+		return payload, nil
+	}
+	if _, ok := companyID(payload); !ok {
+		return payload, nil
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]interface{}, len(p.fields)+1)
+	for field, value := range all {
+		if p.fields[field] {
+			projected[field] = value
+		}
+	}
+	if updated, ok := payload.(core.CompanyUpdatedEvent); ok {
+		changes := make(map[string]core.FieldChange)
+		for field, change := range updated.Changes {
+			if p.fields[field] {
+				changes[field] = change
+			}
+		}
+		projected["changes"] = changes
+	}
+	return projected, nil
+}
+
+// messageKey keys company events by company ID so all events for a company
+// land on one partition in order and compaction keeps its latest state.
+// Other events are keyed by their type.
+func messageKey(eventType string, payload interface{}) []byte {
+	if id, ok := companyID(payload); ok {
+		return []byte(id.String())
+	}
+	return []byte(eventType)
+}
+
+// companyID returns the ID of the company a company event is about
+func companyID(payload interface{}) (uuid.UUID, bool) {
+	switch e := payload.(type) {
+	case *core.Company:
+		return e.ID, true
+	case core.CompanyUpdatedEvent:
+		return e.ID, true
+	case core.CompanyDeletedEvent:
+		return e.ID, true
+	default:
+		return uuid.Nil, false
+	}
+}
+
+// messageHeaders lets consumers filter without decoding the value: the
+// static headers, then the event type, company ID and correlation ID when
+// known
+func (p *Producer) messageHeaders(ctx context.Context, eventType string, payload interface{}) []kafka.Header {
+	headers := append([]kafka.Header(nil), p.headers...)
+	headers = append(headers, kafka.Header{Key: HeaderEventType, Value: []byte(eventType)})
+	if id, ok := companyID(payload); ok {
+		headers = append(headers, kafka.Header{Key: HeaderCompanyID, Value: []byte(id.String())})
+	}
+	if correlationID := core.CorrelationID(ctx); correlationID != "" {
+		headers = append(headers, kafka.Header{Key: HeaderCorrelationID, Value: []byte(correlationID)})
+	}
+	return headers
+}
+
+// tombstone is the compaction delete marker for a company
+func tombstone(id uuid.UUID) kafka.Message {
+	return kafka.Message{Key: []byte(id.String()), Value: nil}
+}
+
+// Healthy reports whether recent writes succeeded. It turns false after
+// failureThreshold consecutive failures and true again on the next success.
+func (p *Producer) Healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failures < failureThreshold
+}
+
+// recordFailure counts a failed write. Once the threshold is reached it
+// logs a warning and triggers a metadata refresh, see refreshMetadata.
+func (p *Producer) recordFailure(err error) {
+	p.mu.Lock()
+	p.failures++
+	failures := p.failures
+	p.mu.Unlock()
+
+	if failures < failureThreshold {
+		return
+	}
+	log.Printf("Warning: kafka producer unhealthy: topic=%s brokers=%v consecutive_failures=%d last_error=%q",
+		p.topic, p.brokers, failures, err)
+
+	if p.refresh != nil && p.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer p.refreshing.Store(false)
+			if err := p.refresh(); err != nil {
+				log.Printf("Warning: kafka metadata refresh failed: topic=%s err=%q", p.topic, err)
+			}
+		}()
+	}
+}
+
+// recordSuccess resets the failure count, logging a recovery
+func (p *Producer) recordSuccess() {
+	p.mu.Lock()
+	failures := p.failures
+	p.failures = 0
+	p.mu.Unlock()
+
+	if failures >= failureThreshold {
+		log.Printf("Kafka producer recovered: topic=%s after_failures=%d", p.topic, failures)
+	}
+}
+
+// refreshMetadata waits until a broker answers, then closes the writer's
+// pooled connections, which also discards its cached metadata. The next
+// write re-dials and re-discovers partition leaders, picking up restarted
+// or moved brokers. While the cluster is down the probe fails and the
+// connections are left alone.
+func (p *Producer) refreshMetadata() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	if err := p.probe(ctx); err != nil {
+		return err
+	}
+	p.transport.CloseIdleConnections()
+	log.Printf("Kafka connections reset: topic=%s", p.topic)
+	return nil
+}
+
+// probeController asks the brokers in turn for the cluster controller and
+// succeeds on the first answer
+func (p *Producer) probeController(ctx context.Context) error {
+	var lastErr error
+	for _, broker := range p.brokers {
+		conn, err := dialBroker(ctx, broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, err = conn.Controller()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// dialBroker connects to one broker, giving up after dialTimeout
+func dialBroker(ctx context.Context, address string) (*kafka.Conn, error) {
+	dialer := &kafka.Dialer{Timeout: dialTimeout}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// ensureTopic creates the topic when auto-creation is configured. Failures
+// are logged rather than fatal: the topic may exist already or be created
+// out of band, and publishes report a missing topic clearly.
+func (p *Producer) ensureTopic() {
+	if p.autoCreate == nil {
+		return
+	}
+	topics := []string{p.topic}
+	if len(p.sinks) > 0 {
+		topics = topics[:0]
+		for _, sink := range p.sinks {
+			topics = append(topics, sink.Topic)
+		}
+	}
+	for _, topic := range topics {
+		config := *p.autoCreate
+		config.Topic = topic
+		if err := p.createTopic(config); err != nil {
+			log.Printf("Warning: kafka topic auto-create failed: topic=%s err=%q", topic, err)
+			continue
+		}
+		log.Printf("Kafka topic ready: topic=%s partitions=%d replication_factor=%d",
+			topic, config.NumPartitions, config.ReplicationFactor)
+	}
+}
+
+// createTopicOnController sends the create request to the cluster
+// controller, the only broker that accepts it. An existing topic is not an
+// error.
+func (p *Producer) createTopicOnController(config kafka.TopicConfig) error {
+	ctx := context.Background()
+	var lastErr error
+	for _, broker := range p.brokers {
+		conn, err := dialBroker(ctx, broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		controller, err := conn.Controller()
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		conn, err = dialBroker(ctx, net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := conn.CreateTopics(config); err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+			return err
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// topicError explains a write rejected because the topic does not exist
+func (p *Producer) topicError(err error) error {
+	if !errors.Is(err, kafka.UnknownTopicOrPartition) {
+		return err
+	}
+	missing := fmt.Sprintf("kafka topic %q does not exist", p.topic)
+	if len(p.sinks) > 1 {
+		missing = fmt.Sprintf("one of kafka topics %q does not exist", p.topic)
+	}
+	if p.autoCreate == nil {
+		return fmt.Errorf("%s; create it or set KAFKA_AUTO_CREATE_TOPIC=true: %w", missing, err)
+	}
+	return fmt.Errorf("%s and could not be created at startup: %w", missing, err)
+}
+
 // Close closes the Kafka writer
 func (p *Producer) Close() error {
 	if p.writer != nil {
@@ -104,3 +714,8 @@ func (p *NoOpProducer) Publish(ctx context.Context, eventType string, payload in
 func (p *NoOpProducer) Close() error {
 	return nil
 }
+
+// Healthy always reports true
+func (p *NoOpProducer) Healthy() bool {
+	return true
+}
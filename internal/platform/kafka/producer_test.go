@@ -0,0 +1,642 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"xm-company-service/internal/core"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer_Encode(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	payload := map[string]interface{}{"name": "Acme"}
+
+	t.Run("native format", func(t *testing.T) {
+		p := &Producer{format: FormatNative}
+
+		value, err := p.encode("CompanyCreated", payload, "", now)
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{
+			"type": "CompanyCreated",
+			"payload": {"name": "Acme"},
+			"timestamp": "2024-01-15T10:30:00Z"
+		}`, string(value))
+	})
+
+	t.Run("cloudevents format", func(t *testing.T) {
+		p := &Producer{format: FormatCloudEvents, source: "urn:xm:companies"}
+
+		value, err := p.encode("CompanyCreated", payload, "", now)
+		require.NoError(t, err)
+
+		var envelope map[string]interface{}
+		require.NoError(t, json.Unmarshal(value, &envelope))
+
+		assert.Equal(t, "1.0", envelope["specversion"])
+		assert.Equal(t, "CompanyCreated", envelope["type"])
+		assert.Equal(t, "urn:xm:companies", envelope["source"])
+		assert.Equal(t, "2024-01-15T10:30:00Z", envelope["time"])
+		assert.Equal(t, "application/json", envelope["datacontenttype"])
+		assert.Equal(t, map[string]interface{}{"name": "Acme"}, envelope["data"])
+
+		_, err = uuid.Parse(envelope["id"].(string))
+		assert.NoError(t, err)
+	})
+}
+
+func TestIsValidFormat(t *testing.T) {
+	assert.True(t, IsValidFormat(FormatNative))
+	assert.True(t, IsValidFormat(FormatCloudEvents))
+	assert.False(t, IsValidFormat("protobuf"))
+	assert.False(t, IsValidFormat(""))
+}
+
+// fakeWriter fails writes while down is set
+type fakeWriter struct {
+	mu   sync.Mutex
+	down bool
+	sent int
+	msgs []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.down {
+		return errors.New("broker unavailable")
+	}
+	w.sent += len(msgs)
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func (w *fakeWriter) setDown(down bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.down = down
+}
+
+func TestProducer_Reconnection(t *testing.T) {
+	ctx := context.Background()
+	writer := &fakeWriter{}
+	refreshed := make(chan struct{}, 1)
+	p := &Producer{
+		writer:  writer,
+		enabled: true,
+		format:  FormatNative,
+		refresh: func() error {
+			refreshed <- struct{}{}
+			return nil
+		},
+	}
+
+	require.NoError(t, p.Publish(ctx, "CompanyCreated", nil))
+	assert.True(t, p.Healthy())
+
+	// Transient broker failure: healthy until the threshold is reached
+	writer.setDown(true)
+	for i := 1; i <= failureThreshold; i++ {
+		assert.True(t, p.Healthy(), "healthy before failure %d", i)
+		assert.Error(t, p.Publish(ctx, "CompanyCreated", nil))
+	}
+	assert.False(t, p.Healthy())
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("metadata refresh was not triggered")
+	}
+
+	// Broker is back: the next successful write restores health
+	writer.setDown(false)
+	require.NoError(t, p.Publish(ctx, "CompanyCreated", nil))
+	assert.True(t, p.Healthy())
+	assert.Equal(t, 2, writer.sent)
+}
+
+// fakeTransport counts connection resets
+type fakeTransport struct {
+	resets int
+}
+
+func (f *fakeTransport) CloseIdleConnections() { f.resets++ }
+
+func TestProducer_RefreshMetadata(t *testing.T) {
+	t.Run("resets connections once a broker answers", func(t *testing.T) {
+		transport := &fakeTransport{}
+		p := &Producer{transport: transport, probe: func(ctx context.Context) error { return nil }}
+
+		require.NoError(t, p.refreshMetadata())
+		assert.Equal(t, 1, transport.resets)
+	})
+
+	t.Run("keeps connections while the cluster is down", func(t *testing.T) {
+		transport := &fakeTransport{}
+		p := &Producer{transport: transport, probe: func(ctx context.Context) error { return errors.New("connection refused") }}
+
+		assert.Error(t, p.refreshMetadata())
+		assert.Zero(t, transport.resets)
+	})
+
+	t.Run("probe is bounded", func(t *testing.T) {
+		var deadline time.Time
+		p := &Producer{transport: &fakeTransport{}, probe: func(ctx context.Context) error {
+			deadline, _ = ctx.Deadline()
+			return nil
+		}}
+
+		require.NoError(t, p.refreshMetadata())
+		assert.WithinDuration(t, time.Now().Add(dialTimeout), deadline, time.Second)
+	})
+}
+
+func TestProducer_HealthyWhenDisabled(t *testing.T) {
+	assert.True(t, NewProducer(nil, "company-events", false).Healthy())
+	assert.True(t, NewNoOpProducer().Healthy())
+}
+
+func TestProducer_MessageKeys(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	tests := []struct {
+		name    string
+		payload interface{}
+		wantKey string
+	}{
+		{"CompanyCreated", &core.Company{ID: id}, id.String()},
+		{"CompanyUpdated", core.CompanyUpdatedEvent{Company: &core.Company{ID: id}}, id.String()},
+		{"CompanyDeleted", core.CompanyDeletedEvent{ID: id}, id.String()},
+		{"ServiceHeartbeat", map[string]interface{}{}, "ServiceHeartbeat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &fakeWriter{}
+			p := &Producer{writer: writer, enabled: true, format: FormatNative}
+
+			require.NoError(t, p.Publish(ctx, tt.name, tt.payload))
+
+			require.Len(t, writer.msgs, 1)
+			assert.Equal(t, tt.wantKey, string(writer.msgs[0].Key))
+		})
+	}
+}
+
+func TestNewProducer_PartitionsByKey(t *testing.T) {
+	p := NewProducer([]string{"localhost:9092"}, "company-events", true)
+	balancer := p.writer.(*kafka.Writer).Balancer
+	require.IsType(t, &kafka.Hash{}, balancer)
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	for i := 0; i < 20; i++ {
+		id := uuid.New()
+		event := kafka.Message{Key: messageKey("CompanyDeleted", core.CompanyDeletedEvent{ID: id}), Value: []byte("{}")}
+		want := balancer.Balance(event, partitions...)
+
+		// Every message for the company, tombstone included, shares a partition
+		for j := 0; j < 5; j++ {
+			assert.Equal(t, want, balancer.Balance(kafka.Message{Key: []byte(id.String()), Value: []byte("{}")}, partitions...))
+		}
+		assert.Equal(t, want, balancer.Balance(tombstone(id), partitions...))
+	}
+}
+
+func TestProducer_DeleteTombstone(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	deleted := core.CompanyDeletedEvent{ID: id, Name: "GoneCo"}
+
+	t.Run("enabled", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative, tombstones: true}
+
+		require.NoError(t, p.Publish(ctx, "CompanyDeleted", deleted))
+
+		require.Len(t, writer.msgs, 2)
+		assert.Equal(t, id.String(), string(writer.msgs[0].Key))
+		assert.NotNil(t, writer.msgs[0].Value)
+
+		tombstone := writer.msgs[1]
+		assert.Equal(t, id.String(), string(tombstone.Key))
+		assert.Nil(t, tombstone.Value)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative}
+
+		require.NoError(t, p.Publish(ctx, "CompanyDeleted", deleted))
+
+		assert.Len(t, writer.msgs, 1)
+	})
+
+	t.Run("only for deletes", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative, tombstones: true}
+
+		require.NoError(t, p.Publish(ctx, "CompanyCreated", &core.Company{ID: id}))
+
+		assert.Len(t, writer.msgs, 1)
+	})
+}
+
+func TestParseHeaders(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		headers, err := ParseHeaders(" env=prod, team = core ,empty=,")
+		require.NoError(t, err)
+		assert.Equal(t, []kafka.Header{
+			{Key: "env", Value: []byte("prod")},
+			{Key: "team", Value: []byte("core")},
+			{Key: "empty", Value: []byte("")},
+		}, headers)
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		headers, err := ParseHeaders("")
+		require.NoError(t, err)
+		assert.Empty(t, headers)
+	})
+
+	for _, raw := range []string{"env", "=prod", "env=a,env=b", "event-type=x", "company-id=x", "correlation-id=x"} {
+		t.Run("invalid "+raw, func(t *testing.T) {
+			_, err := ParseHeaders(raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProducer_Headers(t *testing.T) {
+	id := uuid.New()
+	static := []kafka.Header{{Key: "env", Value: []byte("prod")}}
+
+	headerMap := func(msg kafka.Message) map[string]string {
+		m := make(map[string]string)
+		for _, h := range msg.Headers {
+			m[h.Key] = string(h.Value)
+		}
+		return m
+	}
+
+	t.Run("company event", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative, headers: static, tombstones: true}
+		ctx := core.WithCorrelationID(context.Background(), "req-123")
+
+		require.NoError(t, p.Publish(ctx, "CompanyDeleted", core.CompanyDeletedEvent{ID: id}))
+
+		require.Len(t, writer.msgs, 2)
+		want := map[string]string{
+			"env":            "prod",
+			"event-type":     "CompanyDeleted",
+			"company-id":     id.String(),
+			"correlation-id": "req-123",
+		}
+		assert.Equal(t, want, headerMap(writer.msgs[0]))
+		assert.Equal(t, want, headerMap(writer.msgs[1]), "tombstone carries the same headers")
+	})
+
+	t.Run("event without company or request", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative, headers: static}
+
+		require.NoError(t, p.Publish(context.Background(), "ServiceHeartbeat", map[string]interface{}{}))
+
+		require.Len(t, writer.msgs, 1)
+		assert.Equal(t, map[string]string{"env": "prod", "event-type": "ServiceHeartbeat"}, headerMap(writer.msgs[0]))
+	})
+}
+
+func TestProducer_EnsureTopic(t *testing.T) {
+	t.Run("creates the topic when enabled", func(t *testing.T) {
+		var created []kafka.TopicConfig
+		p := &Producer{
+			topic:      "company-events",
+			autoCreate: &kafka.TopicConfig{NumPartitions: 3, ReplicationFactor: 2},
+			createTopic: func(config kafka.TopicConfig) error {
+				created = append(created, config)
+				return nil
+			},
+		}
+
+		p.ensureTopic()
+
+		assert.Equal(t, []kafka.TopicConfig{
+			{Topic: "company-events", NumPartitions: 3, ReplicationFactor: 2},
+		}, created)
+	})
+
+	t.Run("skips creation when disabled", func(t *testing.T) {
+		p := &Producer{
+			topic: "company-events",
+			createTopic: func(kafka.TopicConfig) error {
+				t.Fatal("topic creation attempted")
+				return nil
+			},
+		}
+
+		p.ensureTopic()
+	})
+
+	t.Run("creation failure is not fatal", func(t *testing.T) {
+		p := &Producer{
+			topic:       "company-events",
+			autoCreate:  &kafka.TopicConfig{NumPartitions: 1, ReplicationFactor: 1},
+			createTopic: func(kafka.TopicConfig) error { return errors.New("not authorized") },
+		}
+
+		assert.NotPanics(t, p.ensureTopic)
+	})
+}
+
+// topicMissingWriter rejects writes as if the topic did not exist
+type topicMissingWriter struct{}
+
+func (topicMissingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return kafka.UnknownTopicOrPartition
+}
+
+func (topicMissingWriter) Close() error { return nil }
+
+func TestProducer_MissingTopic(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("auto-create off suggests enabling it", func(t *testing.T) {
+		p := &Producer{writer: topicMissingWriter{}, enabled: true, format: FormatNative, topic: "company-events"}
+
+		err := p.Publish(ctx, "CompanyCreated", nil)
+
+		require.ErrorIs(t, err, kafka.UnknownTopicOrPartition)
+		assert.Contains(t, err.Error(), `kafka topic "company-events" does not exist`)
+		assert.Contains(t, err.Error(), "KAFKA_AUTO_CREATE_TOPIC=true")
+	})
+
+	t.Run("auto-create on reports the failed creation", func(t *testing.T) {
+		p := &Producer{
+			writer:     topicMissingWriter{},
+			enabled:    true,
+			format:     FormatNative,
+			topic:      "company-events",
+			autoCreate: &kafka.TopicConfig{NumPartitions: 1, ReplicationFactor: 1},
+		}
+
+		err := p.Publish(ctx, "CompanyCreated", nil)
+
+		require.ErrorIs(t, err, kafka.UnknownTopicOrPartition)
+		assert.Contains(t, err.Error(), "could not be created at startup")
+	})
+}
+
+func TestParseEventFields(t *testing.T) {
+	fields, err := ParseEventFields(" name, employees ,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "employees"}, fields)
+
+	fields, err = ParseEventFields("")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	_, err = ParseEventFields("name,secret")
+	assert.EqualError(t, err, `unknown company field "secret"`)
+}
+
+func TestProducer_EventFields(t *testing.T) {
+	ctx := context.Background()
+	desc := "Widgets"
+	company := &core.Company{ID: uuid.New(), Name: "Acme", Description: &desc, Employees: 10, Registered: true, Type: core.TypeCorporations}
+
+	decode := func(t *testing.T, msg kafka.Message) map[string]interface{} {
+		var event struct {
+			Payload map[string]interface{} `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(msg.Value, &event))
+		return event.Payload
+	}
+
+	t.Run("created payload keeps configured fields and id", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative}
+		WithEventFields([]string{"name", "employees"})(p)
+
+		require.NoError(t, p.Publish(ctx, "CompanyCreated", company))
+
+		require.Len(t, writer.msgs, 1)
+		assert.Equal(t, map[string]interface{}{
+			"id":        company.ID.String(),
+			"name":      "Acme",
+			"employees": float64(10),
+		}, decode(t, writer.msgs[0]))
+		assert.Equal(t, []byte(company.ID.String()), writer.msgs[0].Key)
+	})
+
+	t.Run("updated changes are restricted too", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative}
+		WithEventFields([]string{"employees"})(p)
+
+		event := core.CompanyUpdatedEvent{
+			Company: company,
+			Changes: map[string]core.FieldChange{
+				"name":      {Old: "Old", New: "Acme"},
+				"employees": {Old: 5, New: 10},
+			},
+		}
+		require.NoError(t, p.Publish(ctx, "CompanyUpdated", event))
+
+		require.Len(t, writer.msgs, 1)
+		assert.Equal(t, map[string]interface{}{
+			"id":        company.ID.String(),
+			"employees": float64(10),
+			"changes": map[string]interface{}{
+				"employees": map[string]interface{}{"old": float64(5), "new": float64(10)},
+			},
+		}, decode(t, writer.msgs[0]))
+	})
+
+	t.Run("no fields publishes the full payload", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative}
+		WithEventFields(nil)(p)
+
+		created := *company
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		created.CreatedAt = &createdAt
+		require.NoError(t, p.Publish(ctx, "CompanyCreated", &created))
+
+		require.Len(t, writer.msgs, 1)
+		payload := decode(t, writer.msgs[0])
+		assert.Len(t, payload, 7)
+		assert.Equal(t, "2024-03-01T12:00:00Z", payload["createdAt"])
+	})
+}
+
+func TestProducer_ResourceURL(t *testing.T) {
+	ctx := context.Background()
+	company := &core.Company{ID: uuid.New(), Name: "Acme", Type: core.TypeCorporations}
+	want := "https://api.example.com/v1/companies/" + company.ID.String()
+
+	tests := []struct {
+		name      string
+		eventType string
+		payload   interface{}
+		want      string
+	}{
+		{"created", "CompanyCreated", company, want},
+		{"updated", "CompanyUpdated", core.CompanyUpdatedEvent{Company: company}, want},
+		{"deleted", "CompanyDeleted", core.CompanyDeletedEvent{ID: company.ID, Name: "Acme"}, ""},
+		{"heartbeat", "ServiceHeartbeat", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []string{FormatNative, FormatCloudEvents} {
+				writer := &fakeWriter{}
+				p := &Producer{writer: writer, enabled: true, format: format}
+				WithResourceBaseURL("https://api.example.com/v1/")(p)
+
+				require.NoError(t, p.Publish(ctx, tt.eventType, tt.payload))
+
+				require.Len(t, writer.msgs, 1)
+				var envelope map[string]interface{}
+				require.NoError(t, json.Unmarshal(writer.msgs[0].Value, &envelope))
+				key := "resource_url"
+				if format == FormatCloudEvents {
+					key = "resourceurl"
+				}
+				if tt.want == "" {
+					assert.NotContains(t, envelope, key, format)
+				} else {
+					assert.Equal(t, tt.want, envelope[key], format)
+				}
+			}
+		})
+	}
+
+	t.Run("unset base URL omits the field", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative}
+
+		require.NoError(t, p.Publish(ctx, "CompanyCreated", company))
+
+		require.Len(t, writer.msgs, 1)
+		assert.NotContains(t, string(writer.msgs[0].Value), "resource_url")
+	})
+}
+
+func TestParseTopicFormats(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		sinks, err := ParseTopicFormats(" v1:native, v2 : cloudevents : compacted ,")
+		require.NoError(t, err)
+		assert.Equal(t, []TopicFormat{
+			{Topic: "v1", Format: FormatNative},
+			{Topic: "v2", Format: FormatCloudEvents, Compacted: true},
+		}, sinks)
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		sinks, err := ParseTopicFormats("")
+		require.NoError(t, err)
+		assert.Empty(t, sinks)
+	})
+
+	for _, raw := range []string{"v1", ":native", "v1:flat", "v1:native,v1:cloudevents", "v1:native:compact"} {
+		t.Run("invalid "+raw, func(t *testing.T) {
+			_, err := ParseTopicFormats(raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProducer_TopicFormats(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	sinks := []TopicFormat{
+		{Topic: "company-events.v1", Format: FormatNative},
+		{Topic: "company-events.v2", Format: FormatCloudEvents},
+	}
+
+	t.Run("each topic gets its format", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative, source: "urn:xm:companies", sinks: sinks}
+
+		require.NoError(t, p.Publish(ctx, "CompanyCreated", &core.Company{ID: id, Name: "Acme"}))
+
+		require.Len(t, writer.msgs, 2)
+
+		v1 := writer.msgs[0]
+		assert.Equal(t, "company-events.v1", v1.Topic)
+		assert.Equal(t, id.String(), string(v1.Key))
+		var native Event
+		require.NoError(t, json.Unmarshal(v1.Value, &native))
+		assert.Equal(t, "CompanyCreated", native.Type)
+		assert.NotNil(t, native.Payload)
+
+		v2 := writer.msgs[1]
+		assert.Equal(t, "company-events.v2", v2.Topic)
+		assert.Equal(t, id.String(), string(v2.Key))
+		var envelope CloudEvent
+		require.NoError(t, json.Unmarshal(v2.Value, &envelope))
+		assert.Equal(t, "1.0", envelope.SpecVersion)
+		assert.Equal(t, "urn:xm:companies", envelope.Source)
+		assert.Equal(t, "CompanyCreated", envelope.Type)
+		assert.Equal(t, native.Timestamp, envelope.Time)
+	})
+
+	t.Run("tombstones go to compacted topics only", func(t *testing.T) {
+		writer := &fakeWriter{}
+		compacted := []TopicFormat{
+			{Topic: "company-events.v1", Format: FormatNative},
+			{Topic: "company-state", Format: FormatNative, Compacted: true},
+		}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative, tombstones: true, sinks: compacted}
+
+		require.NoError(t, p.Publish(ctx, "CompanyDeleted", core.CompanyDeletedEvent{ID: id}))
+
+		require.Len(t, writer.msgs, 3)
+		assert.Equal(t, "company-events.v1", writer.msgs[0].Topic)
+		assert.NotNil(t, writer.msgs[0].Value)
+		assert.Equal(t, "company-state", writer.msgs[1].Topic)
+		assert.NotNil(t, writer.msgs[1].Value)
+		assert.Equal(t, "company-state", writer.msgs[2].Topic)
+		assert.Nil(t, writer.msgs[2].Value)
+	})
+
+	t.Run("without sinks messages use the writer topic", func(t *testing.T) {
+		writer := &fakeWriter{}
+		p := &Producer{writer: writer, enabled: true, format: FormatNative}
+
+		require.NoError(t, p.Publish(ctx, "CompanyCreated", &core.Company{ID: id}))
+
+		require.Len(t, writer.msgs, 1)
+		assert.Empty(t, writer.msgs[0].Topic)
+	})
+
+	t.Run("auto-create covers every topic", func(t *testing.T) {
+		var created []string
+		p := &Producer{
+			topic:      "company-events.v1,company-events.v2",
+			sinks:      sinks,
+			autoCreate: &kafka.TopicConfig{NumPartitions: 1, ReplicationFactor: 1},
+			createTopic: func(config kafka.TopicConfig) error {
+				created = append(created, config.Topic)
+				return nil
+			},
+		}
+
+		p.ensureTopic()
+
+		assert.Equal(t, []string{"company-events.v1", "company-events.v2"}, created)
+	})
+}
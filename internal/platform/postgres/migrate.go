@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+
+	"xm-company-service/migrations"
+)
+
+// migrationLockID is the pg_advisory_lock key that serializes migrations
+// across replicas and concurrent admin requests
+const migrationLockID = 0x786d636f6d70 // "xmcomp"
+
+// loadMigrations reads the NNN_name.sql files of fsys in order. They are
+// applied in that order and recorded in schema_migrations; the schema
+// version is the number of applied migrations. Never edit or renumber a
+// file once released, only add the next one.
+func loadMigrations(fsys fs.FS) ([]string, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	queries := make([]string, len(names))
+	for i, name := range names {
+		if want := fmt.Sprintf("%03d_", i+1); !strings.HasPrefix(name, want) {
+			return nil, fmt.Errorf("migration %s is out of sequence, want prefix %s", name, want)
+		}
+		query, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		queries[i] = string(query)
+	}
+	return queries, nil
+}
+
+// Migrate applies pending migrations while holding an advisory lock, so only
+// one caller migrates at a time and the others wait and then find nothing to do
+func (r *Repository) Migrate(ctx context.Context) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := acquireMigrationLock(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			log.Printf("Warning: failed to release migration lock: %v", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	queries, err := loadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for i := current; i < len(queries); i++ {
+		if err := applyMigration(ctx, conn, i+1, queries[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		log.Printf("Applied migration %d", i+1)
+	}
+
+	return nil
+}
+
+// acquireMigrationLock takes the session-level migration lock on conn,
+// logging when another replica holds it and we have to wait
+func acquireMigrationLock(ctx context.Context, conn *sql.Conn) error {
+	var acquired bool
+	err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockID).Scan(&acquired)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if acquired {
+		return nil
+	}
+
+	log.Println("Another instance is running migrations, waiting for the migration lock")
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the number of applied migrations
+func (r *Repository) SchemaVersion(ctx context.Context) (int, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return schemaVersion(ctx, conn)
+}
+
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, version int, query string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"xm-company-service/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations_MatchesDirectory(t *testing.T) {
+	queries, err := loadMigrations(migrations.FS)
+	require.NoError(t, err)
+
+	files, err := filepath.Glob("../../../migrations/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	require.Len(t, queries, len(files), "every migration file is embedded")
+	for i, file := range files {
+		want, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), queries[i], filepath.Base(file))
+	}
+}
+
+func TestLoadMigrations_Sequence(t *testing.T) {
+	_, err := loadMigrations(fstest.MapFS{
+		"001_init.sql":  {Data: []byte("SELECT 1")},
+		"003_later.sql": {Data: []byte("SELECT 3")},
+	})
+	assert.EqualError(t, err, "migration 003_later.sql is out of sequence, want prefix 002_")
+}
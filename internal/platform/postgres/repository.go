@@ -4,6 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	"xm-company-service/internal/core"
 
@@ -11,6 +15,35 @@ import (
 	"github.com/lib/pq"
 )
 
+const (
+	// uniqueViolation is the Postgres error code for unique constraint violations
+	uniqueViolation = "23505"
+
+	// readOnlyTransaction is the Postgres error code for writes attempted in a
+	// read-only transaction, as seen on a primary that is failing over
+	readOnlyTransaction = "25006"
+
+	// nameConstraint is the constraint Postgres generates for companies.name UNIQUE
+	nameConstraint = "companies_name_key"
+
+	// primaryKeyConstraint is the constraint Postgres generates for companies.id
+	primaryKeyConstraint = "companies_pkey"
+)
+
+// insertQuery inserts a single company row and returns its creation time
+const insertQuery = `
+	INSERT INTO companies (id, name, description, employees, registered, type)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at`
+
+// likeEscaper escapes LIKE wildcards so user input matches literally.
+// Backslash is the default LIKE escape character in Postgres.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// duplicateKeyDetail matches the Detail of a unique violation, e.g.
+// "Key (name)=(Acme) already exists."
+var duplicateKeyDetail = regexp.MustCompile(`^Key \((\w+)\)=\((.*)\) already exists\.?$`)
+
 // Repository implements core.Repository for PostgreSQL
 type Repository struct {
 	db *sql.DB
@@ -21,24 +54,14 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// Create inserts a new company into the database
+// Create inserts a new company into the database and sets its CreatedAt
 func (r *Repository) Create(ctx context.Context, c *core.Company) error {
-	query := `
-		INSERT INTO companies (id, name, description, employees, registered, type)
-		VALUES ($1, $2, $3, $4, $5, $6)`
-
-	_, err := r.db.ExecContext(ctx, query,
+	err := r.db.QueryRowContext(ctx, insertQuery,
 		c.ID, c.Name, c.Description, c.Employees, c.Registered, c.Type,
-	)
+	).Scan(&c.CreatedAt)
 
 	if err != nil {
-		// Check for unique constraint violation
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" { // unique_violation
-				return core.ErrDuplicateName
-			}
-		}
-		return err
+		return translateError(err)
 	}
 
 	return nil
@@ -47,13 +70,13 @@ func (r *Repository) Create(ctx context.Context, c *core.Company) error {
 // GetByID retrieves a company by its UUID
 func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*core.Company, error) {
 	query := `
-		SELECT id, name, description, employees, registered, type 
-		FROM companies 
+		SELECT id, name, description, employees, registered, type, created_at
+		FROM companies
 		WHERE id = $1`
 
 	var c core.Company
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&c.ID, &c.Name, &c.Description, &c.Employees, &c.Registered, &c.Type,
+		&c.ID, &c.Name, &c.Description, &c.Employees, &c.Registered, &c.Type, &c.CreatedAt,
 	)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -69,13 +92,13 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*core.Company,
 // GetByName retrieves a company by its name (for uniqueness check)
 func (r *Repository) GetByName(ctx context.Context, name string) (*core.Company, error) {
 	query := `
-		SELECT id, name, description, employees, registered, type 
-		FROM companies 
+		SELECT id, name, description, employees, registered, type, created_at
+		FROM companies
 		WHERE name = $1`
 
 	var c core.Company
 	err := r.db.QueryRowContext(ctx, query, name).Scan(
-		&c.ID, &c.Name, &c.Description, &c.Employees, &c.Registered, &c.Type,
+		&c.ID, &c.Name, &c.Description, &c.Employees, &c.Registered, &c.Type, &c.CreatedAt,
 	)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -90,22 +113,42 @@ func (r *Repository) GetByName(ctx context.Context, name string) (*core.Company,
 
 // Update modifies an existing company
 func (r *Repository) Update(ctx context.Context, c *core.Company) error {
+	return update(ctx, r.db, c)
+}
+
+// UpdateMany updates the companies in one transaction, so either all
+// updates are applied or none is
+func (r *Repository) UpdateMany(ctx context.Context, companies []*core.Company) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback()
+
+	for _, c := range companies {
+		if err := update(ctx, tx, c); err != nil {
+			return &core.ItemError{ID: c.ID, Err: err}
+		}
+	}
+	return translateError(tx.Commit())
+}
+
+// execer is the part of *sql.DB and *sql.Tx used by update
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func update(ctx context.Context, db execer, c *core.Company) error {
 	query := `
 		UPDATE companies 
 		SET name = $1, description = $2, employees = $3, registered = $4, type = $5
 		WHERE id = $6`
 
-	result, err := r.db.ExecContext(ctx, query,
+	result, err := db.ExecContext(ctx, query,
 		c.Name, c.Description, c.Employees, c.Registered, c.Type, c.ID,
 	)
 	if err != nil {
-		// Check for unique constraint violation on name update
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" {
-				return core.ErrDuplicateName
-			}
-		}
-		return err
+		return translateError(err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -125,7 +168,7 @@ func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -139,18 +182,246 @@ func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// Migrate creates the companies table if it doesn't exist
-func (r *Repository) Migrate(ctx context.Context) error {
+// EmployeeAggregates computes employee statistics in a single query,
+// optionally restricted to one company type
+func (r *Repository) EmployeeAggregates(ctx context.Context, companyType core.CompanyType) (*core.EmployeeAggregates, error) {
 	query := `
-		CREATE TABLE IF NOT EXISTS companies (
-			id UUID PRIMARY KEY,
-			name VARCHAR(15) NOT NULL UNIQUE,
-			description VARCHAR(3000),
-			employees INT NOT NULL,
-			registered BOOLEAN NOT NULL,
-			type VARCHAR(50) NOT NULL CHECK (type IN ('Corporations', 'NonProfit', 'Cooperative', 'Sole Proprietorship'))
-		)`
-
-	_, err := r.db.ExecContext(ctx, query)
+		SELECT COALESCE(SUM(employees), 0), COALESCE(AVG(employees), 0),
+		       COALESCE(MAX(employees), 0), COALESCE(MIN(employees), 0)
+		FROM companies
+		WHERE $1 = '' OR type = $1`
+
+	var a core.EmployeeAggregates
+	err := r.db.QueryRowContext(ctx, query, companyType).Scan(&a.Sum, &a.Avg, &a.Max, &a.Min)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// SearchByName returns companies whose name contains q, ignoring case.
+// Wildcards in q are escaped, so "50%" only matches a literal "50%". id
+// breaks ties in the order so offset pages never overlap or skip rows.
+func (r *Repository) SearchByName(ctx context.Context, q string, limit, offset int) ([]*core.Company, error) {
+	query := `
+		SELECT id, name, description, employees, registered, type, created_at
+		FROM companies
+		WHERE name ILIKE '%' || $1 || '%'
+		ORDER BY name, id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, likeEscaper.Replace(q), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	companies := []*core.Company{}
+	for rows.Next() {
+		var c core.Company
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Employees, &c.Registered, &c.Type, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		companies = append(companies, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return companies, nil
+}
+
+// EmployeeDistribution counts companies per employee range in a single
+// grouped query. width_bucket numbers the ranges from 1; 0 means below the
+// first bound.
+func (r *Repository) EmployeeDistribution(ctx context.Context, bounds []int) ([]core.EmployeeBucket, error) {
+	query := `
+		SELECT width_bucket(employees, $1::int[]) AS bucket, COUNT(*)
+		FROM companies
+		GROUP BY bucket`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(bounds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]core.EmployeeBucket, len(bounds))
+	for i, min := range bounds {
+		buckets[i].Min = min
+		if i+1 < len(bounds) {
+			max := bounds[i+1]
+			buckets[i].Max = &max
+		}
+	}
+	for rows.Next() {
+		var bucket int
+		var count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket >= 1 && bucket <= len(buckets) {
+			buckets[bucket-1].Count = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// NamePrefixSearch returns companies whose name starts with prefix, ignoring
+// case. Matching lower(name) with LIKE uses companies_name_prefix_idx.
+func (r *Repository) NamePrefixSearch(ctx context.Context, prefix string, limit int) ([]*core.CompanyName, error) {
+	query := `
+		SELECT id, name
+		FROM companies
+		WHERE lower(name) LIKE lower($1) || '%'
+		ORDER BY name, id
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, likeEscaper.Replace(prefix), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []*core.CompanyName{}
+	for rows.Next() {
+		var n core.CompanyName
+		if err := rows.Scan(&n.ID, &n.Name); err != nil {
+			return nil, err
+		}
+		names = append(names, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// FindSimilarNames returns the companies whose name is similar to name. An
+// edit distance of d implies lengths within d of each other, so only those
+// names are fetched and compared here.
+func (r *Repository) FindSimilarNames(ctx context.Context, name string) ([]*core.CompanyName, error) {
+	query := `
+		SELECT id, name
+		FROM companies
+		WHERE char_length(name) BETWEEN $1 AND $2
+		ORDER BY name, id`
+
+	length := utf8.RuneCountInString(name)
+	rows, err := r.db.QueryContext(ctx, query, length-core.MaxSimilarNameDistance, length+core.MaxSimilarNameDistance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []*core.CompanyName{}
+	for rows.Next() {
+		var n core.CompanyName
+		if err := rows.Scan(&n.ID, &n.Name); err != nil {
+			return nil, err
+		}
+		if core.NamesSimilar(name, n.Name) {
+			names = append(names, &n)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// Count returns the number of companies, optionally restricted to one
+// company type
+func (r *Repository) Count(ctx context.Context, companyType core.CompanyType) (int, error) {
+	query := `SELECT COUNT(*) FROM companies WHERE $1 = '' OR type = $1`
+
+	var n int
+	if err := r.db.QueryRowContext(ctx, query, companyType).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ApproxCount reads the planner's row estimate for the companies table,
+// kept current by ANALYZE and autovacuum. It costs one catalog lookup
+// however large the table is, but may lag recent writes.
+func (r *Repository) ApproxCount(ctx context.Context) (int, error) {
+	query := `SELECT reltuples::bigint FROM pg_class WHERE oid = 'companies'::regclass`
+
+	var n int
+	if err := r.db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// SelfTest verifies the schema and write permissions by inserting a throwaway
+// company inside a transaction that is always rolled back
+func (r *Repository) SelfTest(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id := uuid.New()
+	description := "startup self-test"
+	_, err = tx.ExecContext(ctx, insertQuery,
+		id, "selftest-"+id.String()[:6], &description, 0, false, core.TypeCorporations,
+	)
+	if err != nil {
+		return fmt.Errorf("insert probe company: %w", err)
+	}
+
+	var c core.Company
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, name, description, employees, registered, type, created_at
+		FROM companies
+		WHERE id = $1`, id).Scan(
+		&c.ID, &c.Name, &c.Description, &c.Employees, &c.Registered, &c.Type, &c.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("read back probe company: %w", err)
+	}
+
+	return nil
+}
+
+// translateError maps driver errors to domain errors
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case uniqueViolation:
+		if pqErr.Constraint == primaryKeyConstraint {
+			return core.ErrDuplicateID
+		}
+		return duplicateNameError(pqErr)
+	case readOnlyTransaction:
+		return core.ErrReadOnly
+	}
 	return err
 }
+
+// duplicateNameError builds a duplicate-name error from a unique violation.
+// Only the offending name is surfaced; the detail of any other constraint is
+// dropped so unrelated column values never reach the client.
+func duplicateNameError(pqErr *pq.Error) error {
+	if pqErr.Constraint != nameConstraint {
+		return core.ErrDuplicateName
+	}
+	m := duplicateKeyDetail.FindStringSubmatch(pqErr.Detail)
+	if m == nil || m[1] != "name" {
+		return core.ErrDuplicateName
+	}
+	return core.NewDuplicateNameError(m[2])
+}
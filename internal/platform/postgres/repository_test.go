@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"xm-company-service/internal/core"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLikeEscaper(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"acme", "acme"},
+		{"50%", `50\%`},
+		{"a_b", `a\_b`},
+		{`C:\temp`, `C:\\temp`},
+		{`%_\`, `\%\_\\`},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, likeEscaper.Replace(tt.in), tt.in)
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantIs  error
+		wantMsg string
+	}{
+		{
+			name: "name unique violation includes the name",
+			err: &pq.Error{
+				Code:       uniqueViolation,
+				Constraint: nameConstraint,
+				Detail:     "Key (name)=(Acme) already exists.",
+			},
+			wantIs:  core.ErrDuplicateName,
+			wantMsg: `company name already exists: "Acme"`,
+		},
+		{
+			name: "name with spaces and parentheses",
+			err: &pq.Error{
+				Code:       uniqueViolation,
+				Constraint: nameConstraint,
+				Detail:     "Key (name)=(Acme (EU) Ltd) already exists.",
+			},
+			wantIs:  core.ErrDuplicateName,
+			wantMsg: `company name already exists: "Acme (EU) Ltd"`,
+		},
+		{
+			name: "other constraint does not leak detail",
+			err: &pq.Error{
+				Code:       uniqueViolation,
+				Constraint: "companies_other_key",
+				Detail:     "Key (secret)=(hunter2) already exists.",
+			},
+			wantIs:  core.ErrDuplicateName,
+			wantMsg: "company name already exists",
+		},
+		{
+			name: "primary key violation is a duplicate ID",
+			err: &pq.Error{
+				Code:       uniqueViolation,
+				Constraint: primaryKeyConstraint,
+				Detail:     "Key (id)=(6f1c2a4e-3b7d-4c1e-9a2f-0d5e8b7c6a91) already exists.",
+			},
+			wantIs:  core.ErrDuplicateID,
+			wantMsg: "company ID already exists",
+		},
+		{
+			name: "unparseable detail falls back to generic message",
+			err: &pq.Error{
+				Code:       uniqueViolation,
+				Constraint: nameConstraint,
+			},
+			wantIs:  core.ErrDuplicateName,
+			wantMsg: "company name already exists",
+		},
+		{
+			name: "read-only transaction during failover",
+			err: &pq.Error{
+				Code:    readOnlyTransaction,
+				Message: "cannot execute INSERT in a read-only transaction",
+			},
+			wantIs:  core.ErrReadOnly,
+			wantMsg: "database is read-only, retry shortly",
+		},
+		{
+			name:    "other pq errors pass through",
+			err:     &pq.Error{Code: "23502", Message: "null value"},
+			wantMsg: "pq: null value",
+		},
+		{
+			name:    "non-pq errors pass through",
+			err:     errors.New("connection reset"),
+			wantMsg: "connection reset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translateError(tt.err)
+
+			if tt.wantIs != nil {
+				assert.ErrorIs(t, got, tt.wantIs)
+			} else {
+				assert.Equal(t, tt.err, got)
+			}
+			assert.Equal(t, tt.wantMsg, got.Error())
+		})
+	}
+}
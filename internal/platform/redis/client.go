@@ -0,0 +1,397 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeout bounds each command, dial included. It is kept short so
+// a slow or unreachable server costs a cache lookup little before callers
+// fall back to the database; a context deadline that is sooner wins.
+const defaultTimeout = 250 * time.Millisecond
+
+// defaultPoolSize is the number of connections, and so of commands in
+// flight, per client
+const defaultPoolSize = 8
+
+// Error is an error reply sent by the Redis server
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// ErrPoolTimeout is returned when no connection frees up before the
+// command's deadline
+var ErrPoolTimeout = errors.New("redis: timed out waiting for a connection")
+
+// ErrClosed is returned by commands issued after Close
+var ErrClosed = errors.New("redis: client closed")
+
+// Client is a minimal Redis client supporting the commands the cache needs.
+// It keeps a small pool of connections, dialing them on demand and
+// dropping any that hit an I/O error.
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+	timeout  time.Duration
+
+	// slots holds one token per connection that may be in use; idle holds
+	// connections ready for reuse
+	slots chan struct{}
+	idle  chan *conn
+
+	// mu guards closed and active, the connections running a command, and
+	// sending to idle so Close cannot miss a connection
+	mu     sync.Mutex
+	closed bool
+	active map[*conn]struct{}
+}
+
+// conn is one pooled connection
+type conn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// NewClient parses a redis://[[user]:password@]host[:port][/db] URL.
+// The connection is established lazily on the first command.
+func NewClient(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis URL scheme: %q", u.Scheme)
+	}
+
+	c := &Client{
+		addr:    u.Host,
+		timeout: defaultTimeout,
+		slots:   make(chan struct{}, defaultPoolSize),
+		idle:    make(chan *conn, defaultPoolSize),
+		active:  make(map[*conn]struct{}),
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+
+	return c, nil
+}
+
+// Get returns the value stored at key, or nil if the key does not exist
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected GET reply %v", reply)
+	}
+	return value, nil
+}
+
+// Set stores value at key, expiring it after ttl when ttl is positive
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// SetNX stores value at key only if key does not exist, expiring it after
+// ttl when ttl is positive. It reports whether the value was stored.
+func (c *Client) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	reply, err := c.do(ctx, append(args, "NX")...)
+	return err == nil && reply != nil, err
+}
+
+// Del deletes key
+func (c *Client) Del(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", key)
+	return err
+}
+
+// scanCount is the COUNT hint passed to SCAN by DelPrefix
+const scanCount = "100"
+
+// DelPrefix deletes every key starting with prefix and returns the number
+// deleted. Keys are found with SCAN, so concurrent writes may survive.
+func (c *Client) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	pattern := globEscaper.Replace(prefix) + "*"
+
+	deleted, cursor := 0, "0"
+	for {
+		reply, err := c.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", scanCount)
+		if err != nil {
+			return deleted, err
+		}
+		next, keys, err := scanReply(reply)
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			reply, err := c.do(ctx, append([]string{"DEL"}, keys...)...)
+			if err != nil {
+				return deleted, err
+			}
+			if n, ok := reply.(int64); ok {
+				deleted += int(n)
+			}
+		}
+
+		if cursor = next; cursor == "0" {
+			return deleted, nil
+		}
+	}
+}
+
+// globEscaper quotes the characters SCAN MATCH treats as pattern syntax
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// scanReply splits a SCAN reply into the next cursor and the keys found
+func scanReply(reply interface{}) (string, []string, error) {
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) != 2 {
+		return "", nil, fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+	}
+	cursor, ok := parts[0].([]byte)
+	if !ok {
+		return "", nil, fmt.Errorf("redis: unexpected SCAN cursor %v", parts[0])
+	}
+	items, ok := parts[1].([]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("redis: unexpected SCAN keys %v", parts[1])
+	}
+
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		key, ok := item.([]byte)
+		if !ok {
+			return "", nil, fmt.Errorf("redis: unexpected SCAN key %v", item)
+		}
+		keys = append(keys, string(key))
+	}
+	return string(cursor), keys, nil
+}
+
+// Close closes every connection, failing commands in flight, and makes
+// later commands return ErrClosed
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for cn := range c.active {
+		cn.Close()
+	}
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command on a pooled connection and reads its reply. The
+// command is bounded by the sooner of the context deadline and the
+// client's timeout.
+func (c *Client) do(ctx context.Context, args ...string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ErrPoolTimeout
+	}
+	defer func() { <-c.slots }()
+
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	var cn *conn
+	select {
+	case cn = <-c.idle:
+	default:
+		var err error
+		if cn, err = c.connect(ctx, deadline); err != nil {
+			return nil, err
+		}
+	}
+	if !c.acquire(cn) {
+		return nil, ErrClosed
+	}
+
+	if err := cn.SetDeadline(deadline); err != nil {
+		c.release(cn, false)
+		return nil, err
+	}
+
+	reply, err := roundTrip(cn, args)
+	var serverErr Error
+	// Any other error leaves the connection in an unknown state
+	c.release(cn, err == nil || errors.As(err, &serverErr))
+	return reply, err
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// acquire marks cn as running a command, closing it instead and
+// reporting false if the client is closed
+func (c *Client) acquire(cn *conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		cn.Close()
+		return false
+	}
+	c.active[cn] = struct{}{}
+	return true
+}
+
+// release returns cn to the pool when reusable and the client is open,
+// and closes it otherwise
+func (c *Client) release(cn *conn, reusable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.active, cn)
+	if !reusable || c.closed {
+		cn.Close()
+		return
+	}
+	c.idle <- cn
+}
+
+// connect dials the server and authenticates a new connection
+func (c *Client) connect(ctx context.Context, deadline time.Time) (*conn, error) {
+	dialer := net.Dialer{Deadline: deadline}
+	nc, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := nc.SetDeadline(deadline); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	cn := &conn{Conn: nc, rd: bufio.NewReader(nc)}
+
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := roundTrip(cn, auth); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := roundTrip(cn, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+// roundTrip writes a RESP command and reads one reply
+func roundTrip(cn *conn, args []string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(cn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(cn.rd)
+}
+
+// readReply parses a single RESP reply. Arrays are returned as
+// []interface{} holding the parsed elements.
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log"
+	"math"
+	"strconv"
+	"sync/atomic"
 
 	"xm-company-service/internal/core"
 
@@ -14,46 +18,237 @@ import (
 type CompanyService struct {
 	repo     core.Repository
 	producer core.EventProducer
+	names    core.UniquenessChecker
+	bus      *core.EventBus
+	hooks    []ValidationHook
+	quota    *quota
+	defaults map[core.CompanyType]TypeDefaults
+
+	lenientNumbers  bool
+	lenientBooleans bool
+	eventsEnabled   atomic.Bool
+}
+
+// Option configures a CompanyService
+type Option func(*CompanyService)
+
+// WithLenientNumbers makes Patch accept numeric strings such as "25" for
+// integer fields. Non-numeric strings are still rejected.
+func WithLenientNumbers(lenient bool) Option {
+	return func(s *CompanyService) {
+		s.lenientNumbers = lenient
+	}
+}
+
+// WithLenientBooleans makes Create and Patch accept the strings "true",
+// "false", "1" and "0" for boolean fields, see ParseBool
+func WithLenientBooleans(lenient bool) Option {
+	return func(s *CompanyService) {
+		s.lenientBooleans = lenient
+	}
+}
+
+// WithUniquenessChecker replaces the default name check, a repository
+// lookup, with names, e.g. an external coordinator for sharded storage
+func WithUniquenessChecker(names core.UniquenessChecker) Option {
+	return func(s *CompanyService) {
+		s.names = names
+	}
+}
+
+// WithEventBus also publishes every event to bus for in-process subscribers
+func WithEventBus(bus *core.EventBus) Option {
+	return func(s *CompanyService) {
+		s.bus = bus
+	}
+}
+
+// WithValidationHooks adds deployment-specific rules run after
+// core.Company.Validate on create and patch, in the order given
+func WithValidationHooks(hooks ...ValidationHook) Option {
+	return func(s *CompanyService) {
+		s.hooks = append(s.hooks, hooks...)
+	}
+}
+
+// WithMaxCompanies makes Create fail with core.ErrQuotaExceeded once max
+// companies exist. Zero or less means unlimited.
+func WithMaxCompanies(max int) Option {
+	return func(s *CompanyService) {
+		if max > 0 {
+			s.quota = &quota{max: max}
+		}
+	}
+}
+
+// WithTypeDefaults sets per-type values for fields a create omits, see
+// Omitted
+func WithTypeDefaults(defaults map[core.CompanyType]TypeDefaults) Option {
+	return func(s *CompanyService) {
+		s.defaults = defaults
+	}
+}
+
+// WithEventsEnabled sets whether events reach the producer initially, see
+// SetEventsEnabled
+func WithEventsEnabled(enabled bool) Option {
+	return func(s *CompanyService) {
+		s.eventsEnabled.Store(enabled)
+	}
 }
 
 // NewCompanyService creates a new company service
-func NewCompanyService(repo core.Repository, producer core.EventProducer) *CompanyService {
-	return &CompanyService{
+func NewCompanyService(repo core.Repository, producer core.EventProducer, opts ...Option) *CompanyService {
+	s := &CompanyService{
 		repo:     repo,
 		producer: producer,
+		names:    repositoryNames{repo: repo},
 	}
+	s.eventsEnabled.Store(true)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// Create creates a new company
-func (s *CompanyService) Create(ctx context.Context, c *core.Company) (*core.Company, error) {
-	// Validate input
-	if err := c.Validate(); err != nil {
-		return nil, err
-	}
+// repositoryNames is the default core.UniquenessChecker. It looks the name
+// up in the repository and holds no reservation: a concurrent write of the
+// same name is caught by the database unique index instead.
+type repositoryNames struct {
+	repo core.Repository
+}
 
-	// Check for duplicate name
-	existing, err := s.repo.GetByName(ctx, c.Name)
+func (n repositoryNames) Reserve(ctx context.Context, name string) (func(), error) {
+	existing, err := n.repo.GetByName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 	if existing != nil {
-		return nil, core.ErrDuplicateName
+		return nil, core.NewDuplicateNameError(name)
 	}
+	return func() {}, nil
+}
+
+// CreateOption adjusts how Create resolves conflicts
+type CreateOption func(*createOptions)
+
+type createOptions struct {
+	returnExisting bool
+	waitForEvent   bool
+	omitted        map[string]bool
+}
 
-	// Generate new UUID
-	c.ID = uuid.New()
+// ReturnExisting makes Create return the company already holding the name
+// instead of failing with core.ErrDuplicateName
+func ReturnExisting() CreateOption {
+	return func(o *createOptions) {
+		o.returnExisting = true
+	}
+}
+
+// WaitForEvent makes Create report a CompanyCreated event the broker did
+// not acknowledge as an *EventError instead of only logging it
+func WaitForEvent() CreateOption {
+	return func(o *createOptions) {
+		o.waitForEvent = true
+	}
+}
+
+// Omitted lists the fields, by JSON name, that the client did not send, so
+// Create may fill them from the type's defaults
+func Omitted(fields ...string) CreateOption {
+	return func(o *createOptions) {
+		if o.omitted == nil {
+			o.omitted = make(map[string]bool)
+		}
+		for _, field := range fields {
+			o.omitted[field] = true
+		}
+	}
+}
+
+// EventError is returned by Create with WaitForEvent when the company was
+// stored but its event could not be published. Retrying the create would
+// conflict on the name, so callers should treat the company as created.
+type EventError struct {
+	Company *core.Company
+	Err     error
+}
+
+func (e *EventError) Error() string {
+	return "company created but event not published: " + e.Err.Error()
+}
+
+func (e *EventError) Unwrap() error { return e.Err }
+
+// Create creates a new company. With ReturnExisting, a name conflict yields
+// the stored company and existed set instead of an error.
+func (s *CompanyService) Create(ctx context.Context, c *core.Company, opts ...CreateOption) (company *core.Company, existed bool, err error) {
+	var o createOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Normalize, fill per-type defaults and validate input
+	c.Normalize()
+	s.applyDefaults(c, o.omitted)
+	if err := s.validate(c); err != nil {
+		return nil, false, err
+	}
+
+	// Reserve the name for the duration of the create
+	release, err := s.names.Reserve(ctx, c.Name)
+	if err != nil {
+		if o.returnExisting && errors.Is(err, core.ErrDuplicateName) {
+			existing, getErr := s.repo.GetByName(ctx, c.Name)
+			if getErr != nil {
+				return nil, false, getErr
+			}
+			if existing != nil {
+				return existing, true, nil
+			}
+		}
+		return nil, false, err
+	}
+	defer release()
+
+	// Keep a client-supplied ID unless it is taken, otherwise generate one
+	if c.ID != uuid.Nil {
+		_, err := s.repo.GetByID(ctx, c.ID)
+		switch {
+		case err == nil:
+			return nil, false, core.ErrDuplicateID
+		case !errors.Is(err, core.ErrNotFound):
+			return nil, false, err
+		}
+	} else {
+		c.ID = uuid.New()
+	}
+
+	done, err := s.quota.reserve(ctx, s.repo)
+	if err != nil {
+		return nil, false, err
+	}
 
 	// Persist
-	if err := s.repo.Create(ctx, c); err != nil {
-		return nil, err
+	err = s.repo.Create(ctx, c)
+	done(err == nil)
+	if err != nil {
+		// A concurrent create may have taken the name after our check
+		if o.returnExisting && errors.Is(err, core.ErrDuplicateName) {
+			if existing, getErr := s.repo.GetByName(ctx, c.Name); getErr == nil && existing != nil {
+				return existing, true, nil
+			}
+		}
+		return nil, false, err
 	}
 
-	// Emit event (don't fail the operation if event fails)
-	if err := s.producer.Publish(ctx, "CompanyCreated", c); err != nil {
-		log.Printf("Warning: failed to publish CompanyCreated event: %v", err)
+	// Emit event; unless the caller waits for it, a failure doesn't fail the operation
+	if err := s.publish(ctx, "CompanyCreated", c); err != nil && o.waitForEvent {
+		return nil, false, &EventError{Company: c, Err: err}
 	}
 
-	return c, nil
+	return c, false, nil
 }
 
 // Get retrieves a company by ID
@@ -61,55 +256,339 @@ func (s *CompanyService) Get(ctx context.Context, id uuid.UUID) (*core.Company,
 	return s.repo.GetByID(ctx, id)
 }
 
-// PatchInput represents the fields that can be updated
-type PatchInput struct {
-	Name        *string           `json:"name,omitempty"`
-	Description *string           `json:"description,omitempty"`
-	Employees   *int              `json:"employees,omitempty"`
-	Registered  *bool             `json:"registered,omitempty"`
-	Type        *core.CompanyType `json:"type,omitempty"`
+// EmployeeAggregates returns employee statistics, for all companies or only
+// those of companyType when it is not empty
+func (s *CompanyService) EmployeeAggregates(ctx context.Context, companyType core.CompanyType) (*core.EmployeeAggregates, error) {
+	if companyType != "" && !companyType.IsValid() {
+		return nil, core.NewValidationError("type", "invalid company type: %s", companyType)
+	}
+	return s.repo.EmployeeAggregates(ctx, companyType)
 }
 
-// Patch performs a partial update on a company
-func (s *CompanyService) Patch(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*core.Company, error) {
-	// Fetch current state
-	current, err := s.repo.GetByID(ctx, id)
+// SimilarNames lists the names of other companies that are near-duplicates
+// of c's name, see core.NamesSimilar. It is advisory and never blocks a
+// create.
+func (s *CompanyService) SimilarNames(ctx context.Context, c *core.Company) ([]string, error) {
+	similar, err := s.repo.FindSimilarNames(ctx, c.Name)
 	if err != nil {
 		return nil, err
 	}
+	var names []string
+	for _, n := range similar {
+		if n.ID != c.ID {
+			names = append(names, n.Name)
+		}
+	}
+	return names, nil
+}
 
-	// Apply updates
-	if err := applyUpdates(current, updates); err != nil {
-		return nil, err
+// MaxDistributionBuckets caps the number of buckets EmployeeDistribution
+// accepts
+const MaxDistributionBuckets = 20
+
+// EmployeeDistribution counts companies per employee range. bounds are the
+// ascending, non-negative lower bounds of the ranges; the last range is
+// open-ended.
+func (s *CompanyService) EmployeeDistribution(ctx context.Context, bounds []int) ([]core.EmployeeBucket, error) {
+	if len(bounds) == 0 || len(bounds) > MaxDistributionBuckets {
+		return nil, core.NewValidationError("buckets", "buckets must list between 1 and %d boundaries", MaxDistributionBuckets)
+	}
+	for i, bound := range bounds {
+		if bound < 0 {
+			return nil, core.NewValidationError("buckets", "bucket boundaries must not be negative")
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return nil, core.NewValidationError("buckets", "bucket boundaries must be strictly ascending")
+		}
+	}
+	return s.repo.EmployeeDistribution(ctx, bounds)
+}
+
+const (
+	// MinSearchLength rejects searches that would match most companies
+	MinSearchLength = 2
+	// DefaultSearchLimit is the page size when the caller gives none
+	DefaultSearchLimit = 20
+	// MaxSearchLimit caps the page size
+	MaxSearchLimit = 100
+
+	// MinPrefixLength rejects autocomplete prefixes that would match most
+	// companies
+	MinPrefixLength = 2
+	// DefaultAutocompleteLimit is the number of suggestions when the caller
+	// gives no limit
+	DefaultAutocompleteLimit = 10
+	// MaxAutocompleteLimit caps the number of suggestions
+	MaxAutocompleteLimit = 25
+)
+
+// Autocomplete returns the IDs and names of companies whose name starts with
+// prefix, ignoring case. A zero limit means DefaultAutocompleteLimit.
+func (s *CompanyService) Autocomplete(ctx context.Context, prefix string, limit int) ([]*core.CompanyName, error) {
+	if len(prefix) < MinPrefixLength || len(prefix) > core.NameColumnLength {
+		return nil, core.NewValidationError("prefix", "prefix must be between %d and %d characters", MinPrefixLength, core.NameColumnLength)
+	}
+	if limit == 0 {
+		limit = DefaultAutocompleteLimit
+	}
+	if limit < 0 || limit > MaxAutocompleteLimit {
+		return nil, core.NewValidationError("limit", "limit must be between 1 and %d", MaxAutocompleteLimit)
+	}
+	return s.repo.NamePrefixSearch(ctx, prefix, limit)
+}
+
+// SearchByName returns a page of companies whose name contains q, ignoring
+// case. A zero limit means DefaultSearchLimit.
+func (s *CompanyService) SearchByName(ctx context.Context, q string, limit, offset int) ([]*core.Company, error) {
+	if len(q) < MinSearchLength || len(q) > core.NameColumnLength {
+		return nil, core.NewValidationError("q", "search query must be between %d and %d characters", MinSearchLength, core.NameColumnLength)
+	}
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit < 0 || limit > MaxSearchLimit {
+		return nil, core.NewValidationError("limit", "limit must be between 1 and %d", MaxSearchLimit)
+	}
+	if offset < 0 {
+		return nil, core.NewValidationError("offset", "offset must not be negative")
 	}
+	return s.repo.SearchByName(ctx, q, limit, offset)
+}
+
+// Count returns the number of companies, for all companies or only those
+// of companyType when it is not empty
+func (s *CompanyService) Count(ctx context.Context, companyType core.CompanyType) (int, error) {
+	if companyType != "" && !companyType.IsValid() {
+		return 0, core.NewValidationError("type", "invalid company type: %s", companyType)
+	}
+	return s.repo.Count(ctx, companyType)
+}
 
-	// Check for duplicate name if name is being changed
-	if v, ok := updates["name"].(string); ok && v != current.Name {
-		existing, err := s.repo.GetByName(ctx, v)
+// ExactCountThreshold is the estimated table size below which
+// EstimateCount counts exactly, since COUNT(*) is cheap there
+const ExactCountThreshold = 10000
+
+// EstimateCount is Count for callers that can trade accuracy for speed on
+// large tables. It returns the planner's estimate when counting all
+// companies and the table holds at least ExactCountThreshold rows, and an
+// exact count otherwise; exact reports which one was returned.
+func (s *CompanyService) EstimateCount(ctx context.Context, companyType core.CompanyType) (n int, exact bool, err error) {
+	if companyType == "" {
+		estimate, err := s.repo.ApproxCount(ctx)
 		if err != nil {
-			return nil, err
+			return 0, false, err
 		}
-		if existing != nil && existing.ID != id {
-			return nil, core.ErrDuplicateName
+		if estimate >= ExactCountThreshold {
+			return estimate, false, nil
 		}
 	}
+	n, err = s.Count(ctx, companyType)
+	return n, true, err
+}
+
+// preparePatch loads the company and applies updates to it, returning the
+// patched company and its changes without persisting anything. A renamed
+// company holds a reservation on its new name; the caller must call
+// release once the write is done.
+func (s *CompanyService) preparePatch(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (current *core.Company, changes map[string]core.FieldChange, release func(), err error) {
+	// Fetch current state
+	current, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Apply updates with the same normalization as Create
+	before := *current
+	originalName := current.Name
+	if err := applyUpdates(current, updates, s.lenientNumbers, s.lenientBooleans); err != nil {
+		return nil, nil, nil, err
+	}
+	current.Normalize()
 
 	// Validate updated entity
-	if err := current.Validate(); err != nil {
+	if err := s.validate(current); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Reserve the name if it is being changed
+	release = func() {}
+	if current.Name != originalName {
+		release, err = s.names.Reserve(ctx, current.Name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return current, core.Diff(&before, current), release, nil
+}
+
+// MaxPatchBatch caps the number of items in one PatchMany call
+const MaxPatchBatch = 100
+
+// PatchItem is one company's updates in a PatchMany batch
+type PatchItem struct {
+	ID      uuid.UUID
+	Updates map[string]interface{}
+}
+
+// PatchResult is the outcome of one PatchItem. Err is nil when the item was
+// applied, or left as it was when Changed is false.
+type PatchResult struct {
+	ID      uuid.UUID
+	Company *core.Company
+	Changed bool
+	Err     error
+}
+
+// PatchMany applies a batch of partial updates with the same merge and
+// validation as Patch, reporting a result per item in order. When atomic,
+// the batch is written in one transaction only if every item is valid;
+// otherwise nothing is written and the valid items report
+// core.ErrBatchAborted. Without atomic, each item is patched on its own.
+//
+// Items cannot swap or rotate names among themselves. Each new name is
+// checked against the stored companies and the database enforces
+// uniqueness per statement, so renaming to a name another item gives up
+// fails with core.ErrDuplicateName. Rename through an unused name instead.
+func (s *CompanyService) PatchMany(ctx context.Context, items []PatchItem, atomic bool) ([]PatchResult, error) {
+	if len(items) == 0 || len(items) > MaxPatchBatch {
+		return nil, core.NewValidationError("items", "batch must contain between 1 and %d items", MaxPatchBatch)
+	}
+
+	results := make([]PatchResult, len(items))
+	seen := make(map[uuid.UUID]bool, len(items))
+	for i, item := range items {
+		results[i].ID = item.ID
+		if seen[item.ID] {
+			results[i].Err = core.NewValidationError("id", "company %s appears more than once in the batch", item.ID)
+		}
+		seen[item.ID] = true
+	}
+
+	if !atomic {
+		for i, item := range items {
+			if results[i].Err != nil {
+				continue
+			}
+			results[i].Company, results[i].Changed, results[i].Err = s.Patch(ctx, item.ID, item.Updates)
+		}
+		return results, nil
+	}
+
+	// Validate everything before writing anything
+	changes := make([]map[string]core.FieldChange, len(items))
+	names := make(map[string]uuid.UUID, len(items))
+	releases := make([]func(), 0, len(items))
+	failed := false
+	for i, item := range items {
+		if results[i].Err == nil {
+			var release func()
+			results[i].Company, changes[i], release, results[i].Err = s.preparePatch(ctx, item.ID, item.Updates)
+			if release != nil {
+				releases = append(releases, release)
+			}
+		}
+		if results[i].Err == nil {
+			// Two items must not end up with the same name
+			name := results[i].Company.Name
+			if other, ok := names[name]; ok && other != item.ID {
+				results[i].Err = core.NewDuplicateNameError(name)
+			}
+			names[name] = item.ID
+		}
+		if results[i].Err != nil {
+			failed = true
+		}
+	}
+
+	var changed []*core.Company
+	var err error
+	if !failed {
+		for i := range results {
+			if len(changes[i]) > 0 {
+				changed = append(changed, results[i].Company)
+			}
+		}
+		if len(changed) > 0 {
+			err = s.repo.UpdateMany(ctx, changed)
+		}
+	}
+
+	// The new names are written or abandoned, so free their reservations
+	for _, release := range releases {
+		release()
+	}
+
+	var itemErr *core.ItemError
+	if errors.As(err, &itemErr) {
+		for i := range results {
+			if results[i].ID == itemErr.ID {
+				results[i].Err = itemErr.Err
+			}
+		}
+		failed = true
+	} else if err != nil {
 		return nil, err
 	}
 
+	if failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = core.ErrBatchAborted
+			}
+			results[i].Company = nil
+		}
+		return results, nil
+	}
+
+	for i := range results {
+		if len(changes[i]) == 0 {
+			continue
+		}
+		results[i].Changed = true
+		s.publish(ctx, "CompanyUpdated", core.CompanyUpdatedEvent{
+			Company: results[i].Company,
+			Changes: changes[i],
+		})
+	}
+	return results, nil
+}
+
+// PatchInput represents the fields that can be updated
+type PatchInput struct {
+	Name        *string           `json:"name,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Employees   *int              `json:"employees,omitempty"`
+	Registered  *bool             `json:"registered,omitempty"`
+	Type        *core.CompanyType `json:"type,omitempty"`
+}
+
+// Patch performs a partial update on a company. It reports whether anything
+// changed; a patch that leaves every field as it was skips the write and
+// the CompanyUpdated event.
+func (s *CompanyService) Patch(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (*core.Company, bool, error) {
+	current, changes, release, err := s.preparePatch(ctx, id, updates)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+	if len(changes) == 0 {
+		return current, false, nil
+	}
+
 	// Persist
 	if err := s.repo.Update(ctx, current); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	// Emit event
-	if err := s.producer.Publish(ctx, "CompanyUpdated", current); err != nil {
-		log.Printf("Warning: failed to publish CompanyUpdated event: %v", err)
+	// Emit event with the changed fields
+	event := core.CompanyUpdatedEvent{
+		Company: current,
+		Changes: changes,
 	}
+	s.publish(ctx, "CompanyUpdated", event)
 
-	return current, nil
+	return current, true, nil
 }
 
 // Delete removes a company by ID
@@ -124,26 +603,101 @@ func (s *CompanyService) Delete(ctx context.Context, id uuid.UUID) error {
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return err
 	}
+	s.quota.invalidate()
 
 	// Emit event with deleted company info
-	event := map[string]interface{}{
-		"id":   id.String(),
-		"name": company.Name,
+	event := core.CompanyDeletedEvent{
+		ID:   id,
+		Name: company.Name,
 	}
-	if err := s.producer.Publish(ctx, "CompanyDeleted", event); err != nil {
-		log.Printf("Warning: failed to publish CompanyDeleted event: %v", err)
+	s.publish(ctx, "CompanyDeleted", event)
+
+	return nil
+}
+
+// SetEventsEnabled turns event emission to the producer on or off at
+// runtime. Events still go to the EventBus set with WithEventBus, if any.
+func (s *CompanyService) SetEventsEnabled(enabled bool) {
+	s.eventsEnabled.Store(enabled)
+}
+
+// EventsEnabled reports whether events are sent to the producer
+func (s *CompanyService) EventsEnabled() bool {
+	return s.eventsEnabled.Load()
+}
+
+// FlushCache drops every cached company and returns how many entries were
+// dropped. It is a no-op returning zero when the repository is not cached.
+func (s *CompanyService) FlushCache(ctx context.Context) (int, error) {
+	flusher, ok := s.repo.(core.CacheFlusher)
+	if !ok {
+		return 0, nil
 	}
+	return flusher.FlushCache(ctx)
+}
 
+// validate runs the built-in rules and then the registered hooks
+func (s *CompanyService) validate(c *core.Company) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	for _, hook := range s.hooks {
+		if err := hook(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish delivers an event to in-process subscribers and the external
+// producer. Producer failures are logged and returned. Callers publish only
+// after the repository write returned nil, so a failed or rolled-back write
+// never produces an event.
+func (s *CompanyService) publish(ctx context.Context, eventType string, payload interface{}) error {
+	if s.bus != nil {
+		s.bus.Publish(eventType, payload)
+	}
+	if !s.eventsEnabled.Load() {
+		return nil
+	}
+	if err := s.producer.Publish(ctx, eventType, payload); err != nil {
+		log.Printf("Warning: failed to publish %s event: %v", eventType, err)
+		return err
+	}
 	return nil
 }
 
-// applyUpdates applies partial updates to a company
-func applyUpdates(c *core.Company, updates map[string]interface{}) error {
+// ParseBool converts a string sent for the boolean field. Strings are only
+// accepted with WithLenientBooleans.
+func (s *CompanyService) ParseBool(field, value string) (bool, error) {
+	return parseBool(field, value, s.lenientBooleans)
+}
+
+// parseBool converts a string sent for a boolean field: "true" and "1" are
+// true, "false" and "0" are false when lenient, anything else is an error
+func parseBool(field, value string, lenient bool) (bool, error) {
+	if !lenient {
+		return false, core.NewValidationError(field, "%s must be a boolean", field)
+	}
+	switch value {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	default:
+		return false, core.NewValidationError(field, `%s must be a boolean or one of "true", "false", "1", "0", got %q`, field, value)
+	}
+}
+
+// applyUpdates applies partial updates to a company. With lenientNumbers,
+// integer fields also accept strings holding an integer; with
+// lenientBooleans, boolean fields accept the strings parseBool does.
+func applyUpdates(c *core.Company, updates map[string]interface{}, lenientNumbers, lenientBooleans bool) error {
 	if v, ok := updates["name"]; ok {
 		if name, ok := v.(string); ok {
 			c.Name = name
 		} else {
-			return errors.New("name must be a string")
+			return core.NewValidationError("name", "name must be a string")
 		}
 	}
 
@@ -153,36 +707,76 @@ func applyUpdates(c *core.Company, updates map[string]interface{}) error {
 		} else if desc, ok := v.(string); ok {
 			c.Description = &desc
 		} else {
-			return errors.New("description must be a string or null")
+			return core.NewValidationError("description", "description must be a string or null")
 		}
 	}
 
 	if v, ok := updates["employees"]; ok {
 		switch emp := v.(type) {
+		case json.Number:
+			n, err := parseEmployees(emp)
+			if err != nil {
+				return err
+			}
+			c.Employees = n
 		case float64:
+			if emp != math.Trunc(emp) {
+				return core.NewValidationError("employees", "employees must be a whole number")
+			}
 			c.Employees = int(emp)
 		case int:
 			c.Employees = emp
+		case string:
+			n, err := strconv.Atoi(emp)
+			if !lenientNumbers || err != nil {
+				return core.NewValidationError("employees", "employees must be a number")
+			}
+			c.Employees = n
 		default:
-			return errors.New("employees must be a number")
+			return core.NewValidationError("employees", "employees must be a number")
 		}
 	}
 
 	if v, ok := updates["registered"]; ok {
-		if reg, ok := v.(bool); ok {
+		switch reg := v.(type) {
+		case bool:
 			c.Registered = reg
-		} else {
-			return errors.New("registered must be a boolean")
+		case string:
+			b, err := parseBool("registered", reg, lenientBooleans)
+			if err != nil {
+				return err
+			}
+			c.Registered = b
+		default:
+			return core.NewValidationError("registered", "registered must be a boolean")
 		}
 	}
 
 	if v, ok := updates["type"]; ok {
 		if t, ok := v.(string); ok {
-			c.Type = core.CompanyType(t)
+			ct, err := core.ParseCompanyType(t)
+			if err != nil {
+				return err
+			}
+			c.Type = ct
 		} else {
-			return errors.New("type must be a string")
+			return core.NewValidationError("type", "type must be a string")
 		}
 	}
 
 	return nil
 }
+
+// parseEmployees converts a JSON number to an employee count without going
+// through float64, so fractions and out-of-range values are reported rather
+// than silently truncated
+func parseEmployees(num json.Number) (int, error) {
+	n, err := strconv.Atoi(num.String())
+	if err == nil {
+		return n, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, core.NewValidationError("employees", "employees is out of range")
+	}
+	return 0, core.NewValidationError("employees", "employees must be a whole number")
+}
@@ -2,7 +2,13 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"xm-company-service/internal/core"
 
@@ -43,11 +49,66 @@ func (m *MockRepository) Update(ctx context.Context, company *core.Company) erro
 	return args.Error(0)
 }
 
+func (m *MockRepository) UpdateMany(ctx context.Context, companies []*core.Company) error {
+	args := m.Called(ctx, companies)
+	return args.Error(0)
+}
+
 func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockRepository) EmployeeAggregates(ctx context.Context, companyType core.CompanyType) (*core.EmployeeAggregates, error) {
+	args := m.Called(ctx, companyType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*core.EmployeeAggregates), args.Error(1)
+}
+
+func (m *MockRepository) SearchByName(ctx context.Context, q string, limit, offset int) ([]*core.Company, error) {
+	args := m.Called(ctx, q, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.Company), args.Error(1)
+}
+
+func (m *MockRepository) EmployeeDistribution(ctx context.Context, bounds []int) ([]core.EmployeeBucket, error) {
+	args := m.Called(ctx, bounds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]core.EmployeeBucket), args.Error(1)
+}
+
+func (m *MockRepository) FindSimilarNames(ctx context.Context, name string) ([]*core.CompanyName, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.CompanyName), args.Error(1)
+}
+
+func (m *MockRepository) NamePrefixSearch(ctx context.Context, prefix string, limit int) ([]*core.CompanyName, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*core.CompanyName), args.Error(1)
+}
+
+func (m *MockRepository) Count(ctx context.Context, companyType core.CompanyType) (int, error) {
+	args := m.Called(ctx, companyType)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ApproxCount(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
 // MockEventProducer is a mock implementation of core.EventProducer
 type MockEventProducer struct {
 	mock.Mock
@@ -63,6 +124,21 @@ func (m *MockEventProducer) Close() error {
 	return args.Error(0)
 }
 
+// MockUniquenessChecker is a mock implementation of core.UniquenessChecker.
+// Released counts the releases of successful reservations.
+type MockUniquenessChecker struct {
+	mock.Mock
+	Released int
+}
+
+func (m *MockUniquenessChecker) Reserve(ctx context.Context, name string) (func(), error) {
+	args := m.Called(ctx, name)
+	if err := args.Error(0); err != nil {
+		return nil, err
+	}
+	return func() { m.Released++ }, nil
+}
+
 func TestCompanyService_Create(t *testing.T) {
 	ctx := context.Background()
 
@@ -85,9 +161,10 @@ func TestCompanyService_Create(t *testing.T) {
 		// Event is published
 		producer.On("Publish", ctx, "CompanyCreated", mock.AnythingOfType("*core.Company")).Return(nil)
 
-		result, err := svc.Create(ctx, input)
+		result, existed, err := svc.Create(ctx, input)
 
 		require.NoError(t, err)
+		assert.False(t, existed)
 		assert.NotEqual(t, uuid.Nil, result.ID)
 		assert.Equal(t, "TestCo", result.Name)
 		repo.AssertExpectations(t)
@@ -109,10 +186,139 @@ func TestCompanyService_Create(t *testing.T) {
 
 		repo.On("GetByName", ctx, "ExistingCo").Return(existing, nil)
 
-		result, err := svc.Create(ctx, input)
+		result, _, err := svc.Create(ctx, input)
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, core.ErrDuplicateName)
+		assert.Contains(t, err.Error(), "ExistingCo")
+		assert.Nil(t, result)
+	})
+
+	t.Run("duplicate name returns existing when asked", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		existing := &core.Company{ID: uuid.New(), Name: "ExistingCo"}
+		input := &core.Company{
+			Name:       "ExistingCo",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByName", ctx, "ExistingCo").Return(existing, nil)
+
+		result, existed, err := svc.Create(ctx, input, ReturnExisting())
+
+		require.NoError(t, err)
+		assert.True(t, existed)
+		assert.Same(t, existing, result)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("name taken concurrently returns existing when asked", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		existing := &core.Company{ID: uuid.New(), Name: "RaceCo"}
+		input := &core.Company{
+			Name:       "RaceCo",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByName", ctx, "RaceCo").Return(nil, nil).Once()
+		repo.On("Create", ctx, input).Return(core.NewDuplicateNameError("RaceCo"))
+		repo.On("GetByName", ctx, "RaceCo").Return(existing, nil).Once()
+
+		result, existed, err := svc.Create(ctx, input, ReturnExisting())
+
+		require.NoError(t, err)
+		assert.True(t, existed)
+		assert.Same(t, existing, result)
+		producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("client-supplied ID is kept", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		id := uuid.New()
+		input := &core.Company{ID: id, Name: "OwnIDCo", Type: core.TypeCorporations}
+
+		repo.On("GetByName", ctx, "OwnIDCo").Return(nil, nil)
+		repo.On("GetByID", ctx, id).Return(nil, core.ErrNotFound)
+		repo.On("Create", ctx, input).Return(nil)
+		producer.On("Publish", ctx, "CompanyCreated", input).Return(nil)
+
+		result, _, err := svc.Create(ctx, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, id, result.ID)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("client-supplied ID already exists", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		id := uuid.New()
+		input := &core.Company{ID: id, Name: "OwnIDCo", Type: core.TypeCorporations}
+
+		repo.On("GetByName", ctx, "OwnIDCo").Return(nil, nil)
+		repo.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "OtherCo"}, nil)
+
+		result, _, err := svc.Create(ctx, input)
+
+		assert.ErrorIs(t, err, core.ErrDuplicateID)
+		assert.Nil(t, result)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("name is trimmed", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		input := &core.Company{
+			Name:       "  TrimCo  ",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		repo.On("GetByName", ctx, "TrimCo").Return(nil, nil)
+		repo.On("Create", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+		result, _, err := svc.Create(ctx, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, "TrimCo", result.Name)
+	})
+
+	t.Run("validation error - whitespace name", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		input := &core.Company{
+			Name:       "   ",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+
+		result, _, err := svc.Create(ctx, input)
 
 		require.Error(t, err)
-		assert.Equal(t, core.ErrDuplicateName, err)
+		assert.Equal(t, "name is required", err.Error())
 		assert.Nil(t, result)
 	})
 
@@ -128,7 +334,7 @@ func TestCompanyService_Create(t *testing.T) {
 			Type:       core.TypeCorporations,
 		}
 
-		result, err := svc.Create(ctx, input)
+		result, _, err := svc.Create(ctx, input)
 
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "15 characters")
@@ -202,13 +408,140 @@ func TestCompanyService_Patch(t *testing.T) {
 		repo.On("GetByID", ctx, id).Return(existing, nil)
 		repo.On("GetByName", ctx, "NewName").Return(nil, nil)
 		repo.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
-		producer.On("Publish", ctx, "CompanyUpdated", mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", ctx, "CompanyUpdated", mock.AnythingOfType("core.CompanyUpdatedEvent")).Return(nil)
 
-		result, err := svc.Patch(ctx, id, updates)
+		result, changed, err := svc.Patch(ctx, id, updates)
 
 		require.NoError(t, err)
+		assert.True(t, changed)
 		assert.Equal(t, "NewName", result.Name)
 		assert.Equal(t, 20, result.Employees)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("no-op patch skips write and event", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		id := uuid.New()
+		desc := "Same description"
+		existing := &core.Company{
+			ID:          id,
+			Name:        "SameName",
+			Description: &desc,
+			Employees:   10,
+			Registered:  true,
+			Type:        core.TypeCorporations,
+		}
+
+		updates := map[string]interface{}{
+			"name":        " SameName ",
+			"description": "Same description",
+			"employees":   float64(10),
+			"registered":  true,
+			"type":        "Corporations",
+		}
+
+		repo.On("GetByID", ctx, id).Return(existing, nil)
+
+		result, changed, err := svc.Patch(ctx, id, updates)
+
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, "SameName", result.Name)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("event lists changed fields only", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		id := uuid.New()
+		oldDesc := "Old description"
+		existing := &core.Company{
+			ID:          id,
+			Name:        "SameName",
+			Description: &oldDesc,
+			Employees:   10,
+			Registered:  true,
+			Type:        core.TypeCorporations,
+		}
+
+		updates := map[string]interface{}{
+			"name":        "SameName",
+			"description": nil,
+			"employees":   float64(25),
+		}
+
+		repo.On("GetByID", ctx, id).Return(existing, nil)
+		repo.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+
+		var event core.CompanyUpdatedEvent
+		producer.On("Publish", ctx, "CompanyUpdated", mock.Anything).
+			Run(func(args mock.Arguments) { event = args.Get(2).(core.CompanyUpdatedEvent) }).
+			Return(nil)
+
+		_, _, err := svc.Patch(ctx, id, updates)
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]core.FieldChange{
+			"description": {Old: &oldDesc, New: (*string)(nil)},
+			"employees":   {Old: 10, New: 25},
+		}, event.Changes)
+		assert.Equal(t, 25, event.Company.Employees)
+	})
+
+	t.Run("empty and whitespace names are rejected", func(t *testing.T) {
+		for _, name := range []string{"", "   ", "\t\n"} {
+			repo := new(MockRepository)
+			producer := new(MockEventProducer)
+			svc := NewCompanyService(repo, producer)
+
+			id := uuid.New()
+			existing := &core.Company{
+				ID:         id,
+				Name:       "OldName",
+				Employees:  10,
+				Registered: true,
+				Type:       core.TypeCorporations,
+			}
+			repo.On("GetByID", ctx, id).Return(existing, nil)
+
+			result, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": name})
+
+			require.Error(t, err)
+			assert.Equal(t, "name is required", err.Error())
+			assert.Nil(t, result)
+			repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("renamed to a taken name", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		id := uuid.New()
+		existing := &core.Company{
+			ID:         id,
+			Name:       "OldName",
+			Employees:  10,
+			Registered: true,
+			Type:       core.TypeCorporations,
+		}
+		taken := &core.Company{ID: uuid.New(), Name: "TakenName"}
+
+		repo.On("GetByID", ctx, id).Return(existing, nil)
+		repo.On("GetByName", ctx, "TakenName").Return(taken, nil)
+
+		result, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": " TakenName "})
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, core.ErrDuplicateName)
+		assert.Nil(t, result)
 	})
 
 	t.Run("not found", func(t *testing.T) {
@@ -219,12 +552,32 @@ func TestCompanyService_Patch(t *testing.T) {
 		id := uuid.New()
 		repo.On("GetByID", ctx, id).Return(nil, core.ErrNotFound)
 
-		result, err := svc.Patch(ctx, id, map[string]interface{}{"name": "NewName"})
+		result, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": "NewName"})
 
 		require.Error(t, err)
 		assert.Equal(t, core.ErrNotFound, err)
 		assert.Nil(t, result)
 	})
+
+	t.Run("invalid type is rejected when applied", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		id := uuid.New()
+		existing := &core.Company{ID: id, Name: "TypedCo", Type: core.TypeCorporations}
+		repo.On("GetByID", ctx, id).Return(existing, nil)
+
+		result, _, err := svc.Patch(ctx, id, map[string]interface{}{"type": "Bogus"})
+
+		var validationErr *core.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "type", validationErr.Field)
+		assert.EqualError(t, err, "invalid company type: Bogus")
+		assert.Nil(t, result)
+		assert.Equal(t, core.TypeCorporations, existing.Type)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
 }
 
 func TestCompanyService_Delete(t *testing.T) {
@@ -266,3 +619,1097 @@ func TestCompanyService_Delete(t *testing.T) {
 		assert.Equal(t, core.ErrNotFound, err)
 	})
 }
+
+func TestApplyUpdates_Employees(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		lenient bool
+		want    int
+		wantErr bool
+	}{
+		{"strict number", float64(25), false, 25, false},
+		{"strict numeric string", "25", false, 0, true},
+		{"strict non-numeric string", "abc", false, 0, true},
+		{"lenient number", float64(25), true, 25, false},
+		{"lenient numeric string", "25", true, 25, false},
+		{"lenient non-numeric string", "abc", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &core.Company{}
+			err := applyUpdates(c, map[string]interface{}{"employees": tt.value}, tt.lenient, false)
+
+			if tt.wantErr {
+				assert.EqualError(t, err, "employees must be a number")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Employees)
+		})
+	}
+}
+
+func TestApplyUpdates_JSONNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   json.Number
+		want    int
+		wantErr string
+	}{
+		{"integer", "25", 25, ""},
+		{"fraction", "25.5", 0, "employees must be a whole number"},
+		{"exponent", "2.5e1", 0, "employees must be a whole number"},
+		{"huge integer", "123456789012345678901234567890", 0, "employees is out of range"},
+		{"large exact integer", "9007199254740993", 9007199254740993, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &core.Company{}
+			err := applyUpdates(c, map[string]interface{}{"employees": tt.value}, false, false)
+
+			if tt.wantErr != "" {
+				var verr *core.ValidationError
+				require.ErrorAs(t, err, &verr)
+				assert.Equal(t, "employees", verr.Field)
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Employees)
+		})
+	}
+}
+
+func TestCompanyService_EventBus(t *testing.T) {
+	ctx := context.Background()
+
+	repo := new(MockRepository)
+	producer := new(MockEventProducer)
+	bus := core.NewEventBus()
+	svc := NewCompanyService(repo, producer, WithEventBus(bus))
+
+	received := make(chan *core.Company, 1)
+	bus.Subscribe("CompanyCreated", func(payload interface{}) {
+		received <- payload.(*core.Company)
+	})
+
+	repo.On("GetByName", ctx, "BusCo").Return(nil, nil)
+	repo.On("Create", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+	producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(errors.New("broker down"))
+
+	result, _, err := svc.Create(ctx, &core.Company{
+		Name:       "BusCo",
+		Employees:  10,
+		Registered: true,
+		Type:       core.TypeCorporations,
+	})
+	require.NoError(t, err)
+	bus.Wait()
+
+	// In-process subscribers are served even when the external producer fails
+	select {
+	case got := <-received:
+		assert.Equal(t, result.ID, got.ID)
+	default:
+		t.Fatal("subscriber did not receive CompanyCreated")
+	}
+	producer.AssertExpectations(t)
+}
+
+func TestCompanyService_Create_WaitForEvent(t *testing.T) {
+	ctx := context.Background()
+	brokerDown := errors.New("broker down")
+
+	tests := []struct {
+		name       string
+		opts       []CreateOption
+		publishErr error
+		wantErr    bool
+	}{
+		{"acked", []CreateOption{WaitForEvent()}, nil, false},
+		{"not acked", []CreateOption{WaitForEvent()}, brokerDown, true},
+		{"not acked without waiting", nil, brokerDown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			producer := new(MockEventProducer)
+			svc := NewCompanyService(repo, producer)
+
+			repo.On("GetByName", ctx, "EventCo").Return(nil, nil)
+			repo.On("Create", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+			producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(tt.publishErr)
+
+			input := &core.Company{Name: "EventCo", Type: core.TypeCorporations}
+			result, _, err := svc.Create(ctx, input, tt.opts...)
+
+			if !tt.wantErr {
+				require.NoError(t, err)
+				assert.Same(t, input, result)
+				return
+			}
+			var eventErr *EventError
+			require.ErrorAs(t, err, &eventErr)
+			assert.ErrorIs(t, err, brokerDown)
+			assert.Same(t, input, eventErr.Company)
+			assert.Nil(t, result)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApplyUpdates_Registered(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		lenient bool
+		want    bool
+		wantErr string
+	}{
+		{"boolean", true, false, true, ""},
+		{"strict string", "true", false, false, "registered must be a boolean"},
+		{"lenient true", "true", true, true, ""},
+		{"lenient false", "false", true, false, ""},
+		{"lenient one", "1", true, true, ""},
+		{"lenient zero", "0", true, false, ""},
+		{"lenient other string", "yes", true, false, `registered must be a boolean or one of "true", "false", "1", "0", got "yes"`},
+		{"lenient number", float64(1), true, false, "registered must be a boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &core.Company{Registered: !tt.want}
+			err := applyUpdates(c, map[string]interface{}{"registered": tt.value}, false, tt.lenient)
+
+			if tt.wantErr != "" {
+				var validationErr *core.ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, "registered", validationErr.Field)
+				assert.Equal(t, tt.wantErr, validationErr.Message)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Registered)
+		})
+	}
+}
+
+func TestApplyUpdates_ValidationErrors(t *testing.T) {
+	tests := []struct {
+		field string
+		value interface{}
+	}{
+		{"name", 42},
+		{"description", true},
+		{"employees", "many"},
+		{"registered", "yes"},
+		{"type", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			err := applyUpdates(&core.Company{}, map[string]interface{}{tt.field: tt.value}, false, false)
+
+			var validationErr *core.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+}
+
+func TestCompanyService_ValidationHooks(t *testing.T) {
+	ctx := context.Background()
+
+	// A deployment rule rejecting companies named "Forbidden"
+	noForbidden := func(c *core.Company) error {
+		if c.Name == "Forbidden" {
+			return core.NewValidationError("name", "name is reserved")
+		}
+		return nil
+	}
+
+	t.Run("create runs hooks after built-in validation", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithValidationHooks(noForbidden))
+
+		_, _, err := svc.Create(ctx, &core.Company{Name: "Forbidden", Type: core.TypeCorporations})
+		assert.EqualError(t, err, "name is reserved")
+
+		// Built-in rules still run first
+		_, _, err = svc.Create(ctx, &core.Company{Name: "Forbidden", Type: "Bogus"})
+		assert.EqualError(t, err, "invalid company type: Bogus")
+
+		repo.AssertNotCalled(t, "GetByName", mock.Anything, mock.Anything)
+	})
+
+	t.Run("patch runs hooks", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithValidationHooks(noForbidden))
+
+		id := uuid.New()
+		repo.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "Allowed", Type: core.TypeCorporations}, nil)
+
+		_, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": "Forbidden"})
+
+		var validationErr *core.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCompanyService_EstimateCount(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("large table uses the estimate", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+		repo.On("ApproxCount", ctx).Return(ExactCountThreshold, nil)
+
+		n, exact, err := svc.EstimateCount(ctx, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, ExactCountThreshold, n)
+		assert.False(t, exact)
+		repo.AssertNotCalled(t, "Count", mock.Anything, mock.Anything)
+	})
+
+	t.Run("small or unanalyzed table is counted", func(t *testing.T) {
+		for _, estimate := range []int{ExactCountThreshold - 1, -1} {
+			repo := new(MockRepository)
+			svc := NewCompanyService(repo, new(MockEventProducer))
+			repo.On("ApproxCount", ctx).Return(estimate, nil)
+			repo.On("Count", ctx, core.CompanyType("")).Return(12, nil)
+
+			n, exact, err := svc.EstimateCount(ctx, "")
+
+			require.NoError(t, err)
+			assert.Equal(t, 12, n)
+			assert.True(t, exact)
+		}
+	})
+
+	t.Run("filtered counts are exact", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+		repo.On("Count", ctx, core.TypeNonProfit).Return(3, nil)
+
+		n, exact, err := svc.EstimateCount(ctx, core.TypeNonProfit)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.True(t, exact)
+		repo.AssertNotCalled(t, "ApproxCount", mock.Anything)
+	})
+}
+
+func TestCompanyService_SearchByName(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults the limit", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+
+		want := []*core.Company{{ID: uuid.New(), Name: "Acme"}}
+		repo.On("SearchByName", ctx, "acme", DefaultSearchLimit, 0).Return(want, nil)
+
+		got, err := svc.SearchByName(ctx, "acme", 0, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	tests := []struct {
+		name   string
+		q      string
+		limit  int
+		offset int
+		field  string
+	}{
+		{"query too short", "a", 10, 0, "q"},
+		{"query too long", strings.Repeat("a", core.NameColumnLength+1), 10, 0, "q"},
+		{"limit too large", "acme", MaxSearchLimit + 1, 0, "limit"},
+		{"negative offset", "acme", 10, -1, "offset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			svc := NewCompanyService(repo, new(MockEventProducer))
+
+			_, err := svc.SearchByName(ctx, tt.q, tt.limit, tt.offset)
+
+			var validationErr *core.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+			repo.AssertNotCalled(t, "SearchByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCompanyService_PatchMany(t *testing.T) {
+	ctx := context.Background()
+
+	// stored returns fresh copies of two companies, since Patch mutates
+	// what GetByID returns
+	idA, idB := uuid.New(), uuid.New()
+	setup := func() (*CompanyService, *MockRepository, *MockEventProducer) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		repo.On("GetByID", ctx, idA).Return(&core.Company{ID: idA, Name: "Alpha", Employees: 10, Registered: true, Type: core.TypeCorporations}, nil)
+		repo.On("GetByID", ctx, idB).Return(&core.Company{ID: idB, Name: "Beta", Employees: 20, Registered: true, Type: core.TypeNonProfit}, nil)
+		return NewCompanyService(repo, producer), repo, producer
+	}
+
+	t.Run("atomic batch writes changed items in one call", func(t *testing.T) {
+		svc, repo, producer := setup()
+		var written []*core.Company
+		repo.On("UpdateMany", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { written = args.Get(1).([]*core.Company) }).
+			Return(nil)
+		producer.On("Publish", ctx, "CompanyUpdated", mock.AnythingOfType("core.CompanyUpdatedEvent")).Return(nil).Once()
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: idA, Updates: map[string]interface{}{"employees": json.Number("11")}},
+			{ID: idB, Updates: map[string]interface{}{"employees": json.Number("20")}},
+		}, true)
+
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.NoError(t, results[0].Err)
+		assert.True(t, results[0].Changed)
+		assert.Equal(t, 11, results[0].Company.Employees)
+		assert.NoError(t, results[1].Err)
+		assert.False(t, results[1].Changed)
+		require.Len(t, written, 1)
+		assert.Equal(t, idA, written[0].ID)
+		producer.AssertExpectations(t)
+	})
+
+	t.Run("atomic batch with an invalid item writes nothing", func(t *testing.T) {
+		svc, repo, producer := setup()
+		missing := uuid.New()
+		repo.On("GetByID", ctx, missing).Return(nil, core.ErrNotFound)
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: idA, Updates: map[string]interface{}{"employees": json.Number("11")}},
+			{ID: idB, Updates: map[string]interface{}{"employees": json.Number("-1")}},
+			{ID: missing, Updates: map[string]interface{}{"employees": json.Number("5")}},
+		}, true)
+
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.ErrorIs(t, results[0].Err, core.ErrBatchAborted)
+		assert.Nil(t, results[0].Company)
+		var validationErr *core.ValidationError
+		assert.ErrorAs(t, results[1].Err, &validationErr)
+		assert.ErrorIs(t, results[2].Err, core.ErrNotFound)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+		producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("atomic batch reports the item the database rejected", func(t *testing.T) {
+		svc, repo, producer := setup()
+		repo.On("UpdateMany", ctx, mock.Anything).Return(&core.ItemError{ID: idB, Err: core.ErrNotFound})
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: idA, Updates: map[string]interface{}{"employees": json.Number("11")}},
+			{ID: idB, Updates: map[string]interface{}{"employees": json.Number("21")}},
+		}, true)
+
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, core.ErrBatchAborted)
+		assert.ErrorIs(t, results[1].Err, core.ErrNotFound)
+		producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("atomic batch rejects two items taking one name", func(t *testing.T) {
+		svc, repo, _ := setup()
+		repo.On("GetByName", ctx, "Gamma").Return(nil, nil)
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: idA, Updates: map[string]interface{}{"name": "Gamma"}},
+			{ID: idB, Updates: map[string]interface{}{"name": "Gamma"}},
+		}, true)
+
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, core.ErrBatchAborted)
+		assert.ErrorIs(t, results[1].Err, core.ErrDuplicateName)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("repeated id is rejected", func(t *testing.T) {
+		svc, repo, _ := setup()
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: idA, Updates: map[string]interface{}{"employees": json.Number("11")}},
+			{ID: idA, Updates: map[string]interface{}{"employees": json.Number("12")}},
+		}, true)
+
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, core.ErrBatchAborted)
+		var validationErr *core.ValidationError
+		require.ErrorAs(t, results[1].Err, &validationErr)
+		assert.Equal(t, "id", validationErr.Field)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("non-atomic batch applies valid items", func(t *testing.T) {
+		svc, repo, producer := setup()
+		repo.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil).Once()
+		producer.On("Publish", ctx, "CompanyUpdated", mock.Anything).Return(nil).Once()
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: idA, Updates: map[string]interface{}{"employees": json.Number("11")}},
+			{ID: idB, Updates: map[string]interface{}{"employees": json.Number("-1")}},
+		}, false)
+
+		require.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.True(t, results[0].Changed)
+		assert.Error(t, results[1].Err)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("batch size is limited", func(t *testing.T) {
+		svc, _, _ := setup()
+		for _, n := range []int{0, MaxPatchBatch + 1} {
+			_, err := svc.PatchMany(ctx, make([]PatchItem, n), true)
+			var validationErr *core.ValidationError
+			require.ErrorAs(t, err, &validationErr, "%d items", n)
+			assert.Equal(t, "items", validationErr.Field)
+		}
+	})
+}
+
+func TestCompanyService_Autocomplete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults the limit", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+
+		want := []*core.CompanyName{{ID: uuid.New(), Name: "Acme"}}
+		repo.On("NamePrefixSearch", ctx, "ac", DefaultAutocompleteLimit).Return(want, nil)
+
+		got, err := svc.Autocomplete(ctx, "ac", 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		field  string
+	}{
+		{"prefix too short", "a", 10, "prefix"},
+		{"prefix too long", strings.Repeat("a", core.NameColumnLength+1), 10, "prefix"},
+		{"limit too large", "ac", MaxAutocompleteLimit + 1, "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			svc := NewCompanyService(repo, new(MockEventProducer))
+
+			_, err := svc.Autocomplete(ctx, tt.prefix, tt.limit)
+
+			var validationErr *core.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+			repo.AssertNotCalled(t, "NamePrefixSearch", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCompanyService_SimilarNames(t *testing.T) {
+	ctx := context.Background()
+	created := &core.Company{ID: uuid.New(), Name: "Acme"}
+
+	t.Run("excludes the company itself", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+
+		repo.On("FindSimilarNames", ctx, "Acme").Return([]*core.CompanyName{
+			{ID: uuid.New(), Name: "ACME"},
+			{ID: created.ID, Name: "Acme"},
+			{ID: uuid.New(), Name: "Acne"},
+		}, nil)
+
+		names, err := svc.SimilarNames(ctx, created)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ACME", "Acne"}, names)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+
+		repo.On("FindSimilarNames", ctx, "Acme").Return(nil, errors.New("db down"))
+
+		_, err := svc.SimilarNames(ctx, created)
+
+		assert.EqualError(t, err, "db down")
+	})
+}
+
+func TestCompanyService_EmployeeDistribution(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delegates valid bounds", func(t *testing.T) {
+		repo := new(MockRepository)
+		svc := NewCompanyService(repo, new(MockEventProducer))
+
+		want := []core.EmployeeBucket{{Min: 0, Count: 3}}
+		repo.On("EmployeeDistribution", ctx, []int{0}).Return(want, nil)
+
+		got, err := svc.EmployeeDistribution(ctx, []int{0})
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	tests := []struct {
+		name   string
+		bounds []int
+	}{
+		{"empty", nil},
+		{"too many", make([]int, MaxDistributionBuckets+1)},
+		{"negative", []int{-1, 10}},
+		{"descending", []int{0, 50, 10}},
+		{"repeated", []int{0, 10, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			svc := NewCompanyService(repo, new(MockEventProducer))
+
+			_, err := svc.EmployeeDistribution(ctx, tt.bounds)
+
+			var validationErr *core.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "buckets", validationErr.Field)
+			repo.AssertNotCalled(t, "EmployeeDistribution", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCompanyService_NoEventOnRepositoryError(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	stored := &core.Company{ID: id, Name: "Stored", Employees: 10, Registered: true, Type: core.TypeCorporations}
+
+	tests := []struct {
+		name  string
+		setup func(repo *MockRepository)
+		run   func(svc *CompanyService) error
+		want  error
+	}{
+		{
+			name: "create loses a name race",
+			setup: func(repo *MockRepository) {
+				repo.On("GetByName", ctx, "NewCo").Return(nil, nil)
+				repo.On("Create", ctx, mock.Anything).Return(core.ErrDuplicateName)
+			},
+			run: func(svc *CompanyService) error {
+				_, _, err := svc.Create(ctx, &core.Company{Name: "NewCo", Employees: 1, Registered: true, Type: core.TypeCorporations})
+				return err
+			},
+			want: core.ErrDuplicateName,
+		},
+		{
+			name: "create on a read-only database",
+			setup: func(repo *MockRepository) {
+				repo.On("GetByName", ctx, "NewCo").Return(nil, nil)
+				repo.On("Create", ctx, mock.Anything).Return(core.ErrReadOnly)
+			},
+			run: func(svc *CompanyService) error {
+				_, _, err := svc.Create(ctx, &core.Company{Name: "NewCo", Employees: 1, Registered: true, Type: core.TypeCorporations})
+				return err
+			},
+			want: core.ErrReadOnly,
+		},
+		{
+			name: "update loses a name race",
+			setup: func(repo *MockRepository) {
+				repo.On("GetByID", ctx, id).Return(stored, nil)
+				repo.On("GetByName", ctx, "Renamed").Return(nil, nil)
+				repo.On("Update", ctx, mock.Anything).Return(core.ErrDuplicateName)
+			},
+			run: func(svc *CompanyService) error {
+				_, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": "Renamed"})
+				return err
+			},
+			want: core.ErrDuplicateName,
+		},
+		{
+			name: "update of a concurrently deleted company",
+			setup: func(repo *MockRepository) {
+				repo.On("GetByID", ctx, id).Return(stored, nil)
+				repo.On("Update", ctx, mock.Anything).Return(core.ErrNotFound)
+			},
+			run: func(svc *CompanyService) error {
+				_, _, err := svc.Patch(ctx, id, map[string]interface{}{"employees": float64(20)})
+				return err
+			},
+			want: core.ErrNotFound,
+		},
+		{
+			name: "delete fails",
+			setup: func(repo *MockRepository) {
+				repo.On("GetByID", ctx, id).Return(stored, nil)
+				repo.On("Delete", ctx, id).Return(core.ErrReadOnly)
+			},
+			run: func(svc *CompanyService) error {
+				return svc.Delete(ctx, id)
+			},
+			want: core.ErrReadOnly,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			producer := new(MockEventProducer)
+			bus := core.NewEventBus()
+			svc := NewCompanyService(repo, producer, WithEventBus(bus))
+
+			var mu sync.Mutex
+			var delivered []string
+			for _, eventType := range []string{"CompanyCreated", "CompanyUpdated", "CompanyDeleted"} {
+				bus.Subscribe(eventType, func(payload interface{}) {
+					mu.Lock()
+					defer mu.Unlock()
+					delivered = append(delivered, eventType)
+				})
+			}
+			tt.setup(repo)
+
+			err := tt.run(svc)
+			bus.Wait()
+
+			assert.ErrorIs(t, err, tt.want)
+			producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+			assert.Empty(t, delivered)
+		})
+	}
+}
+
+// countingRepository stores nothing but counts creates, which take a
+// moment so concurrent ones overlap; other methods are unused
+type countingRepository struct {
+	core.Repository
+
+	mu   sync.Mutex
+	rows int
+}
+
+func (r *countingRepository) GetByName(ctx context.Context, name string) (*core.Company, error) {
+	return nil, nil
+}
+
+func (r *countingRepository) Create(ctx context.Context, c *core.Company) error {
+	time.Sleep(5 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows++
+	return nil
+}
+
+func (r *countingRepository) Count(ctx context.Context, companyType core.CompanyType) (int, error) {
+	return r.count(), nil
+}
+
+func (r *countingRepository) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rows
+}
+
+func TestCompanyService_MaxCompanies(t *testing.T) {
+	ctx := context.Background()
+	newCompany := func(name string) *core.Company {
+		return &core.Company{Name: name, Employees: 1, Registered: true, Type: core.TypeCorporations}
+	}
+
+	t.Run("under the limit", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithMaxCompanies(2))
+
+		repo.On("GetByName", ctx, "First").Return(nil, nil)
+		repo.On("Count", ctx, core.CompanyType("")).Return(1, nil)
+		repo.On("Create", ctx, mock.Anything).Return(nil)
+		producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+		_, _, err := svc.Create(ctx, newCompany("First"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("at the limit", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithMaxCompanies(2))
+
+		repo.On("GetByName", ctx, mock.Anything).Return(nil, nil)
+		repo.On("Count", ctx, core.CompanyType("")).Return(2, nil).Once()
+
+		_, _, err := svc.Create(ctx, newCompany("Third"))
+		assert.ErrorIs(t, err, core.ErrQuotaExceeded)
+
+		// The count is cached, so a second rejection costs no query
+		_, _, err = svc.Create(ctx, newCompany("Fourth"))
+		assert.ErrorIs(t, err, core.ErrQuotaExceeded)
+
+		repo.AssertNumberOfCalls(t, "Count", 1)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("delete frees a slot", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithMaxCompanies(2))
+
+		id := uuid.New()
+		repo.On("GetByName", ctx, mock.Anything).Return(nil, nil)
+		repo.On("Count", ctx, core.CompanyType("")).Return(2, nil).Once()
+		repo.On("GetByID", ctx, id).Return(&core.Company{ID: id, Name: "Old"}, nil)
+		repo.On("Delete", ctx, id).Return(nil)
+		repo.On("Count", ctx, core.CompanyType("")).Return(1, nil).Once()
+		repo.On("Create", ctx, mock.Anything).Return(nil)
+		producer.On("Publish", ctx, mock.Anything, mock.Anything).Return(nil)
+
+		_, _, err := svc.Create(ctx, newCompany("Third"))
+		require.ErrorIs(t, err, core.ErrQuotaExceeded)
+
+		require.NoError(t, svc.Delete(ctx, id))
+
+		_, _, err = svc.Create(ctx, newCompany("Third"))
+		assert.NoError(t, err)
+		repo.AssertNumberOfCalls(t, "Count", 2)
+	})
+
+	t.Run("concurrent creates cannot overshoot", func(t *testing.T) {
+		repo := &countingRepository{}
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithMaxCompanies(3))
+		producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 20)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _, errs[i] = svc.Create(ctx, newCompany(fmt.Sprintf("Co%d", i)))
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, err := range errs {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.ErrorIs(t, err, core.ErrQuotaExceeded)
+		}
+		assert.Equal(t, 3, created)
+		assert.Equal(t, 3, repo.count())
+	})
+
+	t.Run("failed create frees its slot", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer, WithMaxCompanies(1))
+
+		repo.On("GetByName", ctx, mock.Anything).Return(nil, nil)
+		repo.On("Count", ctx, core.CompanyType("")).Return(0, nil).Once()
+		repo.On("Create", ctx, mock.Anything).Return(errors.New("connection reset")).Once()
+		repo.On("Create", ctx, mock.Anything).Return(nil).Once()
+		producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+		_, _, err := svc.Create(ctx, newCompany("First"))
+		require.Error(t, err)
+
+		_, _, err = svc.Create(ctx, newCompany("First"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("unlimited by default", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		svc := NewCompanyService(repo, producer)
+
+		repo.On("GetByName", ctx, "Any").Return(nil, nil)
+		repo.On("Create", ctx, mock.Anything).Return(nil)
+		producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+		_, _, err := svc.Create(ctx, newCompany("Any"))
+		assert.NoError(t, err)
+		repo.AssertNotCalled(t, "Count", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCompanyService_EventsEnabled(t *testing.T) {
+	ctx := context.Background()
+
+	repo := new(MockRepository)
+	producer := new(MockEventProducer)
+	bus := core.NewEventBus()
+	svc := NewCompanyService(repo, producer, WithEventBus(bus), WithEventsEnabled(false))
+
+	var mu sync.Mutex
+	var delivered int
+	bus.Subscribe("CompanyCreated", func(payload interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered++
+	})
+
+	repo.On("GetByName", ctx, mock.Anything).Return(nil, nil)
+	repo.On("Create", ctx, mock.Anything).Return(nil)
+	producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+	create := func(name string) {
+		_, _, err := svc.Create(ctx, &core.Company{Name: name, Employees: 1, Registered: true, Type: core.TypeCorporations}, WaitForEvent())
+		require.NoError(t, err)
+	}
+
+	// Disabled: the producer is skipped but in-process subscribers still run
+	assert.False(t, svc.EventsEnabled())
+	create("Quiet")
+	producer.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+
+	svc.SetEventsEnabled(true)
+	create("Loud")
+	producer.AssertNumberOfCalls(t, "Publish", 1)
+
+	svc.SetEventsEnabled(false)
+	create("Quiet again")
+	producer.AssertNumberOfCalls(t, "Publish", 1)
+
+	bus.Wait()
+	assert.Equal(t, 3, delivered)
+}
+
+// cachedRepository is a MockRepository with a cache that reports a fixed
+// number of entries flushed
+type cachedRepository struct {
+	*MockRepository
+	entries int
+}
+
+func (r *cachedRepository) FlushCache(ctx context.Context) (int, error) {
+	n := r.entries
+	r.entries = 0
+	return n, nil
+}
+
+func TestCompanyService_FlushCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("flushes a cached repository", func(t *testing.T) {
+		svc := NewCompanyService(&cachedRepository{MockRepository: new(MockRepository), entries: 4}, new(MockEventProducer))
+
+		flushed, err := svc.FlushCache(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, flushed)
+	})
+
+	t.Run("uncached repository flushes nothing", func(t *testing.T) {
+		svc := NewCompanyService(new(MockRepository), new(MockEventProducer))
+
+		flushed, err := svc.FlushCache(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, flushed)
+	})
+}
+
+func TestCompanyService_UniquenessChecker(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	stored := func() *core.Company {
+		return &core.Company{ID: id, Name: "Acme", Employees: 10, Registered: true, Type: core.TypeCorporations}
+	}
+
+	t.Run("create reserves and releases the name", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, producer, WithUniquenessChecker(names))
+
+		names.On("Reserve", ctx, "Globex").Return(nil)
+		repo.On("Create", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+		_, _, err := svc.Create(ctx, &core.Company{Name: "Globex", Employees: 5, Registered: true, Type: core.TypeCorporations})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, names.Released)
+		repo.AssertNotCalled(t, "GetByName", mock.Anything, mock.Anything)
+	})
+
+	t.Run("create fails on a reservation conflict", func(t *testing.T) {
+		repo := new(MockRepository)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, new(MockEventProducer), WithUniquenessChecker(names))
+
+		names.On("Reserve", ctx, "Globex").Return(core.NewDuplicateNameError("Globex"))
+
+		_, _, err := svc.Create(ctx, &core.Company{Name: "Globex", Employees: 5, Registered: true, Type: core.TypeCorporations})
+
+		assert.ErrorIs(t, err, core.ErrDuplicateName)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("create with ReturnExisting returns the holder", func(t *testing.T) {
+		repo := new(MockRepository)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, new(MockEventProducer), WithUniquenessChecker(names))
+
+		names.On("Reserve", ctx, "Acme").Return(core.NewDuplicateNameError("Acme"))
+		repo.On("GetByName", ctx, "Acme").Return(stored(), nil)
+
+		got, existed, err := svc.Create(ctx, &core.Company{Name: "Acme", Employees: 5, Registered: true, Type: core.TypeCorporations}, ReturnExisting())
+
+		require.NoError(t, err)
+		assert.True(t, existed)
+		assert.Equal(t, id, got.ID)
+	})
+
+	t.Run("checker errors are passed through", func(t *testing.T) {
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(new(MockRepository), new(MockEventProducer), WithUniquenessChecker(names))
+
+		names.On("Reserve", ctx, "Globex").Return(errors.New("coordinator unavailable"))
+
+		_, _, err := svc.Create(ctx, &core.Company{Name: "Globex", Employees: 5, Registered: true, Type: core.TypeCorporations}, ReturnExisting())
+
+		assert.EqualError(t, err, "coordinator unavailable")
+	})
+
+	t.Run("rename reserves the new name", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, producer, WithUniquenessChecker(names))
+
+		repo.On("GetByID", ctx, id).Return(stored(), nil)
+		names.On("Reserve", ctx, "Globex").Return(nil)
+		repo.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", ctx, "CompanyUpdated", mock.Anything).Return(nil)
+
+		_, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": "Globex"})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, names.Released)
+	})
+
+	t.Run("rename fails on a reservation conflict", func(t *testing.T) {
+		repo := new(MockRepository)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, new(MockEventProducer), WithUniquenessChecker(names))
+
+		repo.On("GetByID", ctx, id).Return(stored(), nil)
+		names.On("Reserve", ctx, "Globex").Return(core.NewDuplicateNameError("Globex"))
+
+		_, _, err := svc.Patch(ctx, id, map[string]interface{}{"name": "Globex"})
+
+		assert.ErrorIs(t, err, core.ErrDuplicateName)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("patch without rename reserves nothing", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, producer, WithUniquenessChecker(names))
+
+		repo.On("GetByID", ctx, id).Return(stored(), nil)
+		repo.On("Update", ctx, mock.AnythingOfType("*core.Company")).Return(nil)
+		producer.On("Publish", ctx, "CompanyUpdated", mock.Anything).Return(nil)
+
+		_, _, err := svc.Patch(ctx, id, map[string]interface{}{"employees": float64(11)})
+
+		require.NoError(t, err)
+		names.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything)
+	})
+
+	t.Run("atomic batch releases after the write", func(t *testing.T) {
+		repo := new(MockRepository)
+		producer := new(MockEventProducer)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, producer, WithUniquenessChecker(names))
+
+		repo.On("GetByID", ctx, id).Return(stored(), nil)
+		names.On("Reserve", ctx, "Globex").Return(nil)
+		repo.On("UpdateMany", ctx, mock.Anything).Run(func(mock.Arguments) {
+			assert.Zero(t, names.Released, "released before the write")
+		}).Return(nil)
+		producer.On("Publish", ctx, "CompanyUpdated", mock.Anything).Run(func(mock.Arguments) {
+			assert.Equal(t, 1, names.Released, "still held while publishing")
+		}).Return(nil)
+
+		results, err := svc.PatchMany(ctx, []PatchItem{{ID: id, Updates: map[string]interface{}{"name": "Globex"}}}, true)
+
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, 1, names.Released)
+	})
+
+	t.Run("atomic batch cannot swap names", func(t *testing.T) {
+		repo := new(MockRepository)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, new(MockEventProducer), WithUniquenessChecker(names))
+
+		otherID := uuid.New()
+		repo.On("GetByID", ctx, id).Return(stored(), nil)
+		repo.On("GetByID", ctx, otherID).Return(&core.Company{ID: otherID, Name: "Globex", Employees: 5, Registered: true, Type: core.TypeCorporations}, nil)
+		names.On("Reserve", ctx, "Globex").Return(core.NewDuplicateNameError("Globex"))
+		names.On("Reserve", ctx, "Acme").Return(core.NewDuplicateNameError("Acme"))
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: id, Updates: map[string]interface{}{"name": "Globex"}},
+			{ID: otherID, Updates: map[string]interface{}{"name": "Acme"}},
+		}, true)
+
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, core.ErrDuplicateName)
+		assert.ErrorIs(t, results[1].Err, core.ErrDuplicateName)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("aborted atomic batch releases its reservations", func(t *testing.T) {
+		repo := new(MockRepository)
+		names := new(MockUniquenessChecker)
+		svc := NewCompanyService(repo, new(MockEventProducer), WithUniquenessChecker(names))
+
+		missing := uuid.New()
+		repo.On("GetByID", ctx, id).Return(stored(), nil)
+		repo.On("GetByID", ctx, missing).Return(nil, core.ErrNotFound)
+		names.On("Reserve", ctx, "Globex").Return(nil)
+
+		results, err := svc.PatchMany(ctx, []PatchItem{
+			{ID: id, Updates: map[string]interface{}{"name": "Globex"}},
+			{ID: missing, Updates: map[string]interface{}{"employees": json.Number("5")}},
+		}, true)
+
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, core.ErrBatchAborted)
+		assert.Equal(t, 1, names.Released)
+		repo.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"xm-company-service/internal/core"
+)
+
+// TypeDefaults are values Create fills in, per company type, for fields the
+// client omitted. Nil fields have no default.
+type TypeDefaults struct {
+	Employees  *int  `json:"employees,omitempty"`
+	Registered *bool `json:"registered,omitempty"`
+}
+
+// ParseTypeDefaults parses the TYPE_DEFAULTS JSON object, e.g.
+// {"Sole Proprietorship": {"employees": 1, "registered": false}}
+func ParseTypeDefaults(raw string) (map[core.CompanyType]TypeDefaults, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.DisallowUnknownFields()
+
+	var defaults map[core.CompanyType]TypeDefaults
+	if err := dec.Decode(&defaults); err != nil {
+		return nil, err
+	}
+	for companyType := range defaults {
+		if !companyType.IsValid() {
+			return nil, fmt.Errorf("invalid company type: %s", companyType)
+		}
+	}
+	return defaults, nil
+}
+
+// applyDefaults fills fields listed in omitted from the defaults for c's type
+func (s *CompanyService) applyDefaults(c *core.Company, omitted map[string]bool) {
+	defaults, ok := s.defaults[c.Type]
+	if !ok {
+		return
+	}
+	if omitted["employees"] && defaults.Employees != nil {
+		c.Employees = *defaults.Employees
+	}
+	if omitted["registered"] && defaults.Registered != nil {
+		c.Registered = *defaults.Registered
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"xm-company-service/internal/core"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTypeDefaults(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		defaults, err := ParseTypeDefaults(`{"Sole Proprietorship": {"employees": 1, "registered": false}, "NonProfit": {"registered": true}}`)
+		require.NoError(t, err)
+
+		sole := defaults[core.TypeSoleProprietorship]
+		require.NotNil(t, sole.Employees)
+		require.NotNil(t, sole.Registered)
+		assert.Equal(t, 1, *sole.Employees)
+		assert.False(t, *sole.Registered)
+		assert.Nil(t, defaults[core.TypeNonProfit].Employees)
+	})
+
+	for _, raw := range []string{
+		`not json`,
+		`{"Bogus": {"employees": 1}}`,
+		`{"NonProfit": {"name": "x"}}`,
+	} {
+		t.Run("invalid "+raw, func(t *testing.T) {
+			_, err := ParseTypeDefaults(raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCompanyService_Create_TypeDefaults(t *testing.T) {
+	ctx := context.Background()
+	defaults, err := ParseTypeDefaults(`{"Sole Proprietorship": {"employees": 1, "registered": false}}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		company        core.Company
+		omitted        []string
+		wantEmployees  int
+		wantRegistered bool
+	}{
+		{
+			name:           "omitted fields get the type's defaults",
+			company:        core.Company{Name: "Solo", Registered: true, Type: core.TypeSoleProprietorship},
+			omitted:        []string{"employees", "registered"},
+			wantEmployees:  1,
+			wantRegistered: false,
+		},
+		{
+			name:           "explicit values are kept",
+			company:        core.Company{Name: "Solo", Employees: 0, Registered: true, Type: core.TypeSoleProprietorship},
+			wantEmployees:  0,
+			wantRegistered: true,
+		},
+		{
+			name:           "other types have no defaults",
+			company:        core.Company{Name: "Corp", Type: core.TypeCorporations},
+			omitted:        []string{"employees", "registered"},
+			wantEmployees:  0,
+			wantRegistered: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			producer := new(MockEventProducer)
+			svc := NewCompanyService(repo, producer, WithTypeDefaults(defaults))
+
+			repo.On("GetByName", ctx, tt.company.Name).Return(nil, nil)
+			repo.On("Create", ctx, mock.Anything).Return(nil)
+			producer.On("Publish", ctx, "CompanyCreated", mock.Anything).Return(nil)
+
+			company := tt.company
+			result, _, err := svc.Create(ctx, &company, Omitted(tt.omitted...))
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEmployees, result.Employees)
+			assert.Equal(t, tt.wantRegistered, result.Registered)
+		})
+	}
+}
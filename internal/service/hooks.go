@@ -0,0 +1,30 @@
+package service
+
+import "xm-company-service/internal/core"
+
+// ValidationHook is a deployment-specific validation rule. Hooks should
+// return a *core.ValidationError so the API reports a client error.
+type ValidationHook func(*core.Company) error
+
+// Hooks are the built-in optional validation hooks, selectable by name
+// through VALIDATION_HOOKS
+var Hooks = map[string]ValidationHook{
+	"cooperative-registered": CooperativeMustBeRegistered,
+	"nonprofit-description":  NonProfitMustHaveDescription,
+}
+
+// CooperativeMustBeRegistered rejects unregistered cooperatives
+func CooperativeMustBeRegistered(c *core.Company) error {
+	if c.Type == core.TypeCooperative && !c.Registered {
+		return core.NewValidationError("registered", "cooperatives must be registered")
+	}
+	return nil
+}
+
+// NonProfitMustHaveDescription rejects non-profits without a description
+func NonProfitMustHaveDescription(c *core.Company) error {
+	if c.Type == core.TypeNonProfit && (c.Description == nil || *c.Description == "") {
+		return core.NewValidationError("description", "non-profits must have a description")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"xm-company-service/internal/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	description := "helps people"
+	empty := ""
+
+	tests := []struct {
+		name    string
+		hook    string
+		company core.Company
+		wantErr bool
+	}{
+		{"registered cooperative", "cooperative-registered", core.Company{Type: core.TypeCooperative, Registered: true}, false},
+		{"unregistered cooperative", "cooperative-registered", core.Company{Type: core.TypeCooperative}, true},
+		{"unregistered corporation", "cooperative-registered", core.Company{Type: core.TypeCorporations}, false},
+		{"non-profit with description", "nonprofit-description", core.Company{Type: core.TypeNonProfit, Description: &description}, false},
+		{"non-profit without description", "nonprofit-description", core.Company{Type: core.TypeNonProfit}, true},
+		{"non-profit with empty description", "nonprofit-description", core.Company{Type: core.TypeNonProfit, Description: &empty}, true},
+		{"corporation without description", "nonprofit-description", core.Company{Type: core.TypeCorporations}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Hooks[tt.hook](&tt.company)
+
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *core.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"xm-company-service/internal/core"
+)
+
+// quotaCountTTL bounds how stale the cached company count may be, e.g.
+// after creates or deletes on another replica
+const quotaCountTTL = 5 * time.Second
+
+// quota caps the total number of companies. The count is cached so a
+// create does not always cost a COUNT query; this replica's creates and
+// deletes invalidate it. Creates in flight hold a slot until they finish,
+// so concurrent creates on this replica cannot overshoot the cap.
+type quota struct {
+	max int
+
+	mu      sync.Mutex
+	count   int
+	fetched time.Time
+	// pending counts creates that passed reserve and have not finished
+	pending int
+}
+
+// reserve takes a slot for one create, or returns core.ErrQuotaExceeded
+// when the limit has been reached. The caller must call done once the
+// insert has finished, reporting whether it created a company. A nil quota
+// never rejects.
+func (q *quota) reserve(ctx context.Context, repo core.Repository) (done func(created bool), err error) {
+	if q == nil {
+		return func(bool) {}, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.fetched.IsZero() || time.Since(q.fetched) > quotaCountTTL {
+		count, err := repo.Count(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		q.count, q.fetched = count, time.Now()
+	}
+	if q.count+q.pending >= q.max {
+		return nil, core.ErrQuotaExceeded
+	}
+	q.pending++
+
+	return func(created bool) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		q.pending--
+		if created {
+			q.fetched = time.Time{}
+		}
+	}, nil
+}
+
+// invalidate forces the next check to recount
+func (q *quota) invalidate() {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.fetched = time.Time{}
+}
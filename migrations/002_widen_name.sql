@@ -0,0 +1,5 @@
+-- 002_widen_name.sql
+-- Widens the name column so MAX_NAME_LENGTH can be raised without a schema
+-- change. The effective limit is enforced by the application.
+
+ALTER TABLE companies ALTER COLUMN name TYPE VARCHAR(255);
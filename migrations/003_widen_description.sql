@@ -0,0 +1,5 @@
+-- 003_widen_description.sql
+-- Widens the description column so MAX_DESCRIPTION_LENGTH can be changed
+-- without a schema change. The effective limit is enforced by the application.
+
+ALTER TABLE companies ALTER COLUMN description TYPE TEXT;
@@ -0,0 +1,5 @@
+-- 004_name_prefix_index.sql
+-- Indexes lowercased names for the autocomplete prefix search. text_pattern_ops
+-- lets LIKE 'prefix%' use the index regardless of the database collation.
+
+CREATE INDEX IF NOT EXISTS companies_name_prefix_idx ON companies (lower(name) text_pattern_ops);
@@ -0,0 +1,35 @@
+-- 005_reconcile_init.sql
+-- Brings databases migrated by the service before it applied these files in
+-- line with 001_init.sql: its embedded first migration lacked the timestamps,
+-- the employees check, the name index and the updated_at trigger. Existing
+-- rows get the migration time as created_at.
+
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'companies_employees_check' AND conrelid = 'companies'::regclass
+    ) THEN
+        ALTER TABLE companies ADD CONSTRAINT companies_employees_check CHECK (employees >= 0);
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_companies_name ON companies(name);
+
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_companies_updated_at ON companies;
+CREATE TRIGGER update_companies_updated_at
+    BEFORE UPDATE ON companies
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,10 @@
+// Package migrations holds the SQL schema migrations. They are embedded so
+// the service's migrator applies the same files as make db-migrate.
+package migrations
+
+import "embed"
+
+// FS holds the NNN_name.sql migrations, applied in file name order
+//
+//go:embed *.sql
+var FS embed.FS